@@ -0,0 +1,162 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package cache provides fixed-capacity LRU and LFU caches.  Entries are
+// kept in a skiplist in eviction order, least recently or least frequently
+// used first, and found by key through a map.  Peek takes O(1) time; Get
+// and Put also promote the entry, and Put may evict one, in O(log(N)).
+// Because the skiplist is indexed by position, an entry's rank in eviction
+// order is also available.
+//
+package cache
+
+import (
+	"github.com/glenn-brown/skiplist"
+)
+
+// A Cache maps keys to values, evicting the coldest entry when full.  Like
+// a skiplist, it is not safe for concurrent use.
+//
+type Cache struct {
+	capacity int
+	entries  map[interface{}]*skiplist.Element
+	l        *skiplist.T
+	lfu      bool
+	tick     uint64
+}
+
+// An entry is the Value of each list element.
+//
+type entry struct {
+	key, value interface{}
+}
+
+// A rank orders entries by use count, for LFU caches, then by last use,
+// implementing skiplist.FastKey.
+//
+type rank struct {
+	hits, tick uint64
+}
+
+func (a rank) Less(b interface{}) bool {
+	r := b.(rank)
+	return a.hits < r.hits || a.hits == r.hits && a.tick < r.tick
+}
+
+func (a rank) Score() float64 { return float64(a.hits) }
+
+// NewLRU returns a new, empty cache holding up to capacity entries, which
+// evicts the least recently used entry.  It panics if capacity is less
+// than 1.
+//
+func NewLRU(capacity int) *Cache { return newCache(capacity, false) }
+
+// NewLFU returns a new, empty cache holding up to capacity entries, which
+// evicts the least frequently used entry, or the least recently used of
+// those equally used.  It panics if capacity is less than 1.
+//
+func NewLFU(capacity int) *Cache { return newCache(capacity, true) }
+
+func newCache(capacity int, lfu bool) *Cache {
+	if capacity < 1 {
+		panic("cache: capacity less than 1")
+	}
+	return &Cache{capacity, map[interface{}]*skiplist.Element{}, skiplist.New(), lfu, 0}
+}
+
+// Len returns the number of entries in the cache in O(1) time.
+//
+func (c *Cache) Len() int { return len(c.entries) }
+
+// Get returns the value for key, and marks it used, in O(log(N)) time.  If
+// key is not in the cache, ok is false.
+//
+func (c *Cache) Get(key interface{}) (value interface{}, ok bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.touch(e)
+	return e.Value.(*entry).value, true
+}
+
+// Peek returns the value for key without marking it used, in O(1) time.
+// If key is not in the cache, ok is false.
+//
+func (c *Cache) Peek(key interface{}) (value interface{}, ok bool) {
+	if e, ok := c.entries[key]; ok {
+		return e.Value.(*entry).value, true
+	}
+	return nil, false
+}
+
+// Put sets the value for key, and marks it used, in O(log(N)) time.  If
+// that makes the cache too full, the coldest entry is evicted and
+// returned, with evicted true.
+//
+func (c *Cache) Put(key, value interface{}) (evictedKey, evictedValue interface{}, evicted bool) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*entry).value = value
+		c.touch(e)
+		return nil, nil, false
+	}
+	if len(c.entries) == c.capacity {
+		old := c.l.RemoveN(0).Value.(*entry)
+		delete(c.entries, old.key)
+		evictedKey, evictedValue, evicted = old.key, old.value, true
+	}
+	c.tick++
+	c.entries[key] = c.l.InsertElem(rank{c.hits(0), c.tick}, &entry{key, value})
+	return evictedKey, evictedValue, evicted
+}
+
+// Remove removes key from the cache in O(log(N)) time, and reports whether
+// it was present.
+//
+func (c *Cache) Remove(key interface{}) bool {
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	c.l.RemoveElement(e)
+	delete(c.entries, key)
+	return true
+}
+
+// Rank returns the position of key in eviction order, from 0 for the next
+// entry to be evicted, in O(log(N)) time.  If key is not in the cache, ok
+// is false.
+//
+func (c *Cache) Rank(key interface{}) (rank int, ok bool) {
+	if e, ok := c.entries[key]; ok {
+		return c.l.Pos(e.Key()), true
+	}
+	return -1, false
+}
+
+// Keys returns the keys of the cache in eviction order, the next to be
+// evicted first, in O(N) time.
+//
+func (c *Cache) Keys() []interface{} {
+	keys := make([]interface{}, 0, len(c.entries))
+	for e := c.l.Front(); nil != e; e = e.Next() {
+		keys = append(keys, e.Value.(*entry).key)
+	}
+	return keys
+}
+
+// Function touch marks the entry of element e used.
+//
+func (c *Cache) touch(e *skiplist.Element) {
+	c.tick++
+	c.l.ChangeKey(e, rank{c.hits(e.Key().(rank).hits + 1), c.tick})
+}
+
+// Function hits returns n for LFU caches, which order by use count, and 0
+// for LRU caches, which do not.
+//
+func (c *Cache) hits(n uint64) uint64 {
+	if c.lfu {
+		return n
+	}
+	return 0
+}
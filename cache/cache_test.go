@@ -0,0 +1,65 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	t.Parallel()
+	c := NewLRU(3)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Error(v, ok)
+	}
+	if k, v, ok := c.Put("d", 4); !ok || k != "b" || v != 2 {
+		t.Error(k, v, ok)
+	}
+	if _, ok := c.Peek("b"); ok {
+		t.Error("evicted key found")
+	}
+	c.Peek("c")
+	c.Put("a", 10)
+	if s := fmt.Sprint(c.Keys()); s != "[c d a]" {
+		t.Error(s)
+	}
+	if r, ok := c.Rank("d"); !ok || r != 1 {
+		t.Error(r, ok)
+	}
+	if !c.Remove("c") || c.Remove("c") || c.Len() != 2 {
+		t.Error("Remove")
+	}
+	if _, _, ok := c.Put("e", 5); ok || c.Len() != 3 {
+		t.Error("evicted below capacity")
+	}
+}
+
+func TestLFU(t *testing.T) {
+	t.Parallel()
+	c := NewLFU(3)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+	c.Get("a")
+	c.Get("a")
+	c.Get("c")
+	if k, _, _ := c.Put("d", 4); k != "b" {
+		t.Error(k)
+	}
+	if k, _, _ := c.Put("e", 5); k != "d" {
+		t.Error(k)
+	}
+	if s := fmt.Sprint(c.Keys()); s != "[e c a]" {
+		t.Error(s)
+	}
+	defer func() {
+		if r := recover(); r != "cache: capacity less than 1" {
+			t.Error(r)
+		}
+	}()
+	NewLFU(0)
+}
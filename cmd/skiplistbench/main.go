@@ -0,0 +1,141 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Command skiplistbench runs configurable workloads against the
+// skiplist variants and prints a comparison table, so construction
+// options can be chosen for the hardware at hand.
+//
+// Usage:
+//
+//	skiplistbench [-n size] [-ops count] [-reads fraction] [-dist seq|uniform|zipf] [-variants list]
+//
+// The variants are ascending and descending lists, as made by New and
+// NewDescending, and ascending lists with construction options:
+// quarter (WithProbability(0.25)), keyindex (WithKeyIndex), finger
+// (WithFinger), noscores (WithoutScores) and bloom (WithBloomFilter).
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/glenn-brown/skiplist"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	size     = flag.Int("n", 100000, "number of entries loaded before the workload runs")
+	ops      = flag.Int("ops", 1000000, "number of operations in the workload")
+	reads    = flag.Float64("reads", 0.9, "fraction of operations that are reads; the rest insert and remove")
+	dist     = flag.String("dist", "uniform", "key distribution: seq, uniform, or zipf")
+	variants = flag.String("variants", "ascending,descending,quarter,keyindex,finger,noscores,bloom", "comma-separated variants to compare")
+	seed     = flag.Uint64("seed", 1, "workload random seed")
+)
+
+// The constructors of the variants that may be compared.
+//
+var constructors = map[string]func() *skiplist.T{
+	"ascending":  skiplist.New,
+	"descending": skiplist.NewDescending,
+	"quarter":    func() *skiplist.T { return skiplist.New().WithProbability(0.25) },
+	"keyindex":   func() *skiplist.T { return skiplist.New().WithKeyIndex() },
+	"finger":     func() *skiplist.T { return skiplist.New().WithFinger() },
+	"noscores":   func() *skiplist.T { return skiplist.New().WithoutScores() },
+	"bloom":      func() *skiplist.T { return skiplist.New().WithBloomFilter(*size, hashKey) },
+}
+
+// Function hashKey hashes the int keys of the workload for bloom filters.
+//
+func hashKey(key interface{}) uint64 { return uint64(key.(int)) * 0x9e3779b97f4a7c15 }
+
+// A result records the measurements for one variant.
+//
+type result struct {
+	variant     string
+	load, mixed time.Duration
+}
+
+func main() {
+	flag.Parse()
+	if *ops < 1 {
+		fmt.Fprintln(os.Stderr, "skiplistbench: ops must be positive")
+		os.Exit(2)
+	}
+	keys, err := workloadKeys(*dist, *ops, *size, *seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "skiplistbench:", err)
+		os.Exit(2)
+	}
+	var results []result
+	for _, name := range strings.Split(*variants, ",") {
+		nu, ok := constructors[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "skiplistbench: unknown variant %q\n", name)
+			os.Exit(2)
+		}
+		results = append(results, run(name, nu(), keys))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "variant\tload ns/op\tmixed ns/op\t\n")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t\n", r.variant,
+			r.load.Nanoseconds()/int64(*size), r.mixed.Nanoseconds()/int64(*ops))
+	}
+	w.Flush()
+}
+
+// Function run loads list l with *size entries and then applies the
+// mixed workload using keys, returning the timings.
+//
+func run(variant string, l *skiplist.T, keys []int) result {
+	r := result{variant: variant}
+	start := time.Now()
+	for _, key := range rand.New(rand.NewPCG(*seed, 0)).Perm(*size) {
+		l.Insert(key, key)
+	}
+	r.load = time.Since(start)
+
+	rng := rand.New(rand.NewPCG(*seed, 1))
+	start = time.Now()
+	for _, key := range keys {
+		if rng.Float64() < *reads {
+			l.Get(key)
+		} else {
+			l.Insert(key, key)
+			l.Remove(key)
+		}
+	}
+	r.mixed = time.Since(start)
+	return r
+}
+
+// Function workloadKeys returns cnt keys in [0,n) drawn from the named distribution.
+//
+func workloadKeys(dist string, cnt, n int, seed uint64) ([]int, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("size must be positive")
+	}
+	rng := rand.New(rand.NewPCG(seed, 2))
+	keys := make([]int, cnt)
+	switch dist {
+	case "seq":
+		for i := range keys {
+			keys[i] = i % n
+		}
+	case "uniform":
+		for i := range keys {
+			keys[i] = rng.IntN(n)
+		}
+	case "zipf":
+		z := rand.NewZipf(rng, 1.1, 1, uint64(n-1))
+		for i := range keys {
+			keys[i] = int(z.Uint64())
+		}
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", dist)
+	}
+	return keys, nil
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestWorkloadKeys(t *testing.T) {
+	t.Parallel()
+	for _, dist := range []string{"seq", "uniform", "zipf"} {
+		keys, err := workloadKeys(dist, 1000, 10, 1)
+		if nil != err || len(keys) != 1000 {
+			t.Fatal(dist, err)
+		}
+		for _, k := range keys {
+			if k < 0 || 10 <= k {
+				t.Fatal(dist, k)
+			}
+		}
+	}
+	if _, err := workloadKeys("normal", 1, 10, 1); nil == err {
+		t.Error("unknown distribution accepted")
+	}
+	if _, err := workloadKeys("seq", 1, 0, 1); nil == err {
+		t.Error("empty size accepted")
+	}
+}
+
+func TestRun(t *testing.T) {
+	*size = 100
+	keys, _ := workloadKeys("uniform", 1000, *size, 1)
+	for name, nu := range constructors {
+		l := nu()
+		if r := run(name, l, keys); r.variant != name || l.Len() != *size {
+			t.Error(name, l.Len())
+		}
+	}
+}
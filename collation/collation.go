@@ -0,0 +1,34 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package collation provides skiplists of string keys sorted by the
+// collation rules of a language, as implemented by golang.org/x/text/collate,
+// rather than by byte value.
+//
+package collation
+
+import (
+	"encoding/binary"
+	"github.com/glenn-brown/skiplist"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// New returns a new skiplist of string keys sorted by the collation rules
+// of the language tag, adjusted by opts.  Keys that collate equally, such
+// as those differing only in case under collate.IgnoreCase, are equal
+// keys.  Keys score by the first 8 bytes of their collation keys, so most
+// comparisons need not consult the collator.  Like the list, the collator
+// is not safe for concurrent use.
+//
+func New(tag language.Tag, opts ...collate.Option) *skiplist.T {
+	c := collate.New(tag, opts...)
+	var buf collate.Buffer
+	return skiplist.NewWithScore(func(a, b interface{}) bool {
+		return c.CompareString(a.(string), b.(string)) < 0
+	}, func(a interface{}) float64 {
+		var hi [8]byte
+		copy(hi[:], c.KeyFromString(&buf, a.(string)))
+		buf.Reset()
+		return float64(binary.BigEndian.Uint64(hi[:]))
+	})
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package collation
+
+import (
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"math/rand"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct {
+		tag  language.Tag
+		opts []collate.Option
+		keys []string
+	}{
+		{language.German, nil, []string{"Apfel", "Äpfel", "Bär", "Zebra"}},
+		{language.Swedish, nil, []string{"Apfel", "Bär", "Zebra", "Äpfel"}},
+		{language.English, []collate.Option{collate.IgnoreCase}, []string{"a", "B", "c"}},
+	} {
+		l := New(c.tag, c.opts...)
+		for _, i := range rand.Perm(len(c.keys)) {
+			l.Insert(c.keys[i], i)
+		}
+		for i, e := 0, l.Front(); nil != e; i, e = i+1, e.Next() {
+			if e.Value != i {
+				t.Error(c.tag, l)
+			}
+		}
+		for i, k := range c.keys {
+			if l.Get(k) != i {
+				t.Error(c.tag, k)
+			}
+		}
+	}
+	if l := New(language.English, collate.IgnoreCase).Set("b", 1).Set("B", 2); l.Len() != 1 {
+		t.Error(l)
+	}
+}
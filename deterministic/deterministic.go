@@ -0,0 +1,247 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package deterministic implements a 1-2-3 skiplist, a skiplist whose
+// levels are maintained deterministically rather than randomly, as
+// described by Munro, Papadakis, and Sedgewick.  Between any two
+// consecutive elements at a level, there are one, two, or three elements
+// at the level below, so Get, Set, and Remove require O(log(N)) time in
+// the worst case, not merely in expectation.  Keys are unique, and are
+// ordered as by skiplist.New.
+//
+// Unlike skiplist.T, the list is not indexed by position.
+//
+package deterministic
+
+import (
+	"github.com/glenn-brown/ordinal"
+)
+
+// A deterministic.T is a 1-2-3 skiplist.  Each level is a linked list of
+// nodes ending with a node acting as +infinity, recognizable by its nil
+// right link.  Every node above the bottom level heads a "gap" of nodes
+// at the level below, starting at its down link and ending just before
+// the gap of its right neighbour, and its key is the greatest in the gap.
+// Gaps hold two to four nodes, except under the single node of the top
+// level.
+//
+type T struct {
+	cnt  int
+	head *node
+	less func(a, b interface{}) bool
+}
+
+type node struct {
+	down  *node
+	key   interface{}
+	right *node
+	value interface{}
+}
+
+// New returns a new, empty list in O(1) time.
+//
+func New() *T {
+	return &T{head: &node{}}
+}
+
+// Len returns the number of entries in the list in O(1) time.
+//
+func (l *T) Len() int { return l.cnt }
+
+// Get returns the value for key, with ok true, in O(log(N)) time.  If key
+// is absent, nil and false are returned.
+//
+func (l *T) Get(key interface{}) (value interface{}, ok bool) {
+	if 0 == l.cnt {
+		return nil, false
+	}
+	n := l.head
+	for {
+		for l.before(n, key) {
+			n = n.right
+		}
+		if nil == n.down {
+			if nil == n.right || l.less(key, n.key) {
+				return nil, false
+			}
+			return n.value, true
+		}
+		n = n.down
+	}
+}
+
+// Set sets the value for key, inserting key if it is absent, in O(log(N))
+// time, and returns the list.
+//
+func (l *T) Set(key interface{}, value interface{}) *T {
+	if nil == l.less {
+		l.less, _ = ordinal.Fns(key)
+	}
+
+	// Descend, splitting full gaps so that the insertion cannot overfill one.
+
+	n := l.head
+	for nil != n.down {
+		for l.before(n, key) {
+			n = n.right
+		}
+		if 4 == gapSize(n) {
+			second := n.down.right
+			n.right = &node{down: second.right, key: n.key, right: n.right}
+			n.key = second.key
+			if l.before(n, key) {
+				n = n.right
+			}
+		}
+		n = n.down
+	}
+
+	// At the bottom, update or insert before n by moving n's entry to a new node.
+
+	for l.before(n, key) {
+		n = n.right
+	}
+	if nil != n.right && !l.less(key, n.key) {
+		n.value = value
+	} else {
+		n.right = &node{key: n.key, right: n.right, value: n.value}
+		n.key, n.value = key, value
+		l.cnt++
+	}
+
+	// If the top node was split, add a level above it.
+
+	if nil != l.head.right {
+		l.head = &node{down: l.head}
+	}
+	return l
+}
+
+// Remove removes key from the list, returning its value with ok true, in
+// O(log(N)) time.  If key is absent, nil and false are returned.
+//
+func (l *T) Remove(key interface{}) (value interface{}, ok bool) {
+	if 0 == l.cnt {
+		return nil, false
+	}
+
+	// Descend, refilling minimal gaps so that the removal cannot empty one,
+	// and noting the nodes keyed by key, which must be rekeyed.
+
+	var parent *node
+	var rekey []*node
+	n := l.head
+	for nil != n.down {
+		var left *node
+		for l.before(n, key) {
+			left, n = n, n.right
+		}
+		if nil != parent && 2 == gapSize(n) {
+			n = l.refill(parent, left, n)
+		}
+		if nil != n.right && !l.less(key, n.key) {
+			rekey = append(rekey, n)
+		}
+		parent, n = n, n.down
+	}
+
+	// At the bottom, unlink the entry.
+
+	var left *node
+	for l.before(n, key) {
+		left, n = n, n.right
+	}
+	if nil == n.right || l.less(key, n.key) {
+		l.lower()
+		return nil, false
+	}
+	value = n.value
+	if nil == left {
+		// Node n may head a gap, so keep it, replacing its entry with the next.
+		next := n.right
+		n.key, n.value, n.right = next.key, next.value, next.right
+	} else {
+		left.right = n.right
+		for _, r := range rekey {
+			r.key = left.key
+		}
+	}
+	l.cnt--
+	l.lower()
+	return value, true
+}
+
+// All returns an iterator, in the form of an iter.Seq2, over the key/value
+// pairs of the list, in order.  Each step requires O(1) time.
+//
+func (l *T) All() func(yield func(key, value interface{}) bool) {
+	return func(yield func(key, value interface{}) bool) {
+		n := l.head
+		for nil != n.down {
+			n = n.down
+		}
+		for ; nil != n.right && yield(n.key, n.value); n = n.right {
+		}
+	}
+}
+
+// Function before returns true iff node n sorts before key.
+//
+func (l *T) before(n *node, key interface{}) bool {
+	return nil != n.right && l.less(n.key, key)
+}
+
+// Function refill grows the two-node gap of node n, which is in the gap of
+// parent and follows left, if not nil, by borrowing a node from the gap of
+// a neighbour of n within parent's gap, or by merging with that neighbour.
+// It returns the node whose gap now holds that of n.
+//
+func (l *T) refill(parent, left, n *node) *node {
+	if nil != n.right && n.right != gapStop(parent) {
+		right := n.right
+		if 2 < gapSize(right) {
+			first := right.down
+			n.key, right.down = first.key, first.right
+		} else {
+			n.key, n.right = right.key, right.right
+		}
+		return n
+	}
+	if 2 < gapSize(left) {
+		prev, last := left.down, left.down.right
+		for last.right != n.down {
+			prev, last = last, last.right
+		}
+		left.key, n.down = prev.key, last
+		return n
+	}
+	left.key, left.right = n.key, n.right
+	return left
+}
+
+// Function lower removes top levels holding nothing but the top node's gap.
+//
+func (l *T) lower() {
+	for nil != l.head.down && nil == l.head.down.right {
+		l.head = l.head.down
+	}
+}
+
+// Function gapStop returns the node following the gap of node n, or nil if
+// the gap ends its level.
+//
+func gapStop(n *node) *node {
+	if nil == n.right {
+		return nil
+	}
+	return n.right.down
+}
+
+// Function gapSize returns the number of nodes in the gap of node n.
+//
+func gapSize(n *node) (size int) {
+	stop := gapStop(n)
+	for g := n.down; g != stop; g = g.right {
+		size++
+	}
+	return size
+}
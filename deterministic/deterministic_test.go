@@ -0,0 +1,133 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package deterministic
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	t.Parallel()
+	l := New()
+	if _, ok := l.Get(1); ok {
+		t.Error("found key in empty list")
+	}
+	if _, ok := l.Remove(1); ok {
+		t.Error("removed key from empty list")
+	}
+	l.Set(2, "b").Set(1, "a").Set(3, "c").Set(2, "B")
+	if v, ok := l.Get(2); !ok || v != "B" || l.Len() != 3 {
+		t.Error(v, ok)
+	}
+	if v, ok := l.Remove(1); !ok || v != "a" {
+		t.Error(v, ok)
+	}
+	s := ""
+	l.All()(func(key, value interface{}) bool {
+		s += fmt.Sprint(key, ":", value, " ")
+		return true
+	})
+	if s != "2:B 3:c " {
+		t.Error(s)
+	}
+}
+
+func TestT_random(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewSource(1))
+	l, m := New(), map[int]int{}
+	for i := 0; i < 20000; i++ {
+		k := rng.Intn(2000)
+		if 0 == rng.Intn(3) {
+			v, ok := l.Remove(k)
+			if w, had := m[k]; ok != had || ok && v != w {
+				t.Fatal("remove", k, v, ok, w, had)
+			}
+			delete(m, k)
+		} else {
+			l.Set(k, i)
+			m[k] = i
+		}
+		if 0 == i%97 {
+			if err := valid(l); nil != err {
+				t.Fatal(i, err)
+			}
+		}
+	}
+	for k, w := range m {
+		if v, ok := l.Get(k); !ok || v != w {
+			t.Error(k, v, ok, w)
+		}
+	}
+	for k := range m {
+		l.Remove(k)
+	}
+	if err := valid(l); nil != err || 0 != l.Len() || nil != l.head.down {
+		t.Error(err, l.Len())
+	}
+}
+
+func TestT_sequential(t *testing.T) {
+	t.Parallel()
+	l := New()
+	for i := 0; i < 4096; i++ {
+		l.Set(i, i)
+	}
+	if err := valid(l); nil != err {
+		t.Error(err)
+	}
+	for i := 0; i < 4096; i += 2 {
+		l.Remove(i)
+	}
+	if err := valid(l); nil != err || 2048 != l.Len() {
+		t.Error(err)
+	}
+}
+
+// Function valid checks the structural invariants of a list, including
+// that its height is logarithmic in its length.
+//
+func valid(l *T) error {
+	if nil != l.head.right {
+		return fmt.Errorf("top level has more than one node")
+	}
+	height := 0
+	for level := l.head; nil != level; level = level.down {
+		height++
+		for n := level; nil != n; n = n.right {
+			if nil != n.right && nil != n.right.right && !l.less(n.key, n.right.key) {
+				return fmt.Errorf("keys out of order at height %d", height)
+			}
+			if nil == n.down {
+				continue
+			}
+			if size := gapSize(n); size < 2 || 4 < size {
+				return fmt.Errorf("gap of %d nodes at height %d", size, height)
+			}
+			last := n.down
+			for last.right != gapStop(n) {
+				last = last.right
+			}
+			if (nil == n.right) != (nil == last.right) || nil != n.right && last.key != n.key {
+				return fmt.Errorf("key %v is not the greatest in its gap at height %d", n.key, height)
+			}
+		}
+	}
+	cnt, limit := 0, 2
+	l.All()(func(key, value interface{}) bool {
+		cnt++
+		return true
+	})
+	for n := cnt; n > 0; n >>= 1 {
+		limit++
+	}
+	if cnt != l.cnt {
+		return fmt.Errorf("length %d, but %d entries", l.cnt, cnt)
+	}
+	if height > limit {
+		return fmt.Errorf("height %d for %d entries", height, cnt)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package interval provides sets of half-open intervals [Lo,Hi) supporting
+// stabbing and overlap queries.  Intervals are kept in a skiplist ordered
+// by Lo, whose links carry the greatest Hi of the intervals they skip, so
+// a query visits only links that may hold a match, in O((K+1)*log(N))
+// time for K matches.
+//
+package interval
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"math"
+)
+
+// A Set is a set of intervals.  Like a skiplist, it is not safe for
+// concurrent use.
+//
+type Set struct {
+	elems map[*Interval]*skiplist.Element
+	l     *skiplist.T
+}
+
+// An Interval is the half-open interval [Lo,Hi), with an associated Value.
+//
+type Interval struct {
+	Lo, Hi float64
+	Value  interface{}
+}
+
+// New returns a new, empty Set.
+//
+func New() *Set {
+	return &Set{map[*Interval]*skiplist.Element{}, skiplist.New().WithMonoid(math.Inf(-1),
+		func(v interface{}) interface{} { return v.(*Interval).Hi },
+		func(a, b interface{}) interface{} { return math.Max(a.(float64), b.(float64)) })}
+}
+
+// Len returns the number of intervals in the set in O(1) time.
+//
+func (s *Set) Len() int { return len(s.elems) }
+
+// Insert adds the interval [lo,hi) with value to the set in O(log(N))
+// time, returning it for Remove.  Intervals may be inserted more than
+// once.  Insert panics unless lo < hi.
+//
+func (s *Set) Insert(lo, hi float64, value interface{}) *Interval {
+	if !(lo < hi) {
+		panic("interval: empty interval")
+	}
+	iv := &Interval{lo, hi, value}
+	s.elems[iv] = s.l.InsertElem(lo, iv)
+	return iv
+}
+
+// Remove removes interval iv, as returned by Insert, from the set in
+// O(log(N)) time, and reports whether it was in the set.
+//
+func (s *Set) Remove(iv *Interval) bool {
+	e, ok := s.elems[iv]
+	if !ok {
+		return false
+	}
+	s.l.RemoveElement(e)
+	delete(s.elems, iv)
+	return true
+}
+
+// Stab returns the intervals containing point, ordered by Lo, in
+// O((K+1)*log(N)) time, where K is the number returned.
+//
+func (s *Set) Stab(point float64) []*Interval {
+	return s.search(math.Nextafter(point, math.Inf(1)), point)
+}
+
+// Overlaps returns the intervals overlapping [lo,hi), ordered by Lo, in
+// O((K+1)*log(N)) time, where K is the number returned.
+//
+func (s *Set) Overlaps(lo, hi float64) []*Interval {
+	if !(lo < hi) {
+		return nil
+	}
+	return s.search(hi, lo)
+}
+
+// Function search returns the intervals with Lo less than below and Hi
+// greater than above.
+//
+func (s *Set) search(below, above float64) []*Interval {
+	elems := s.l.SearchRange(math.Inf(-1), below, func(hi interface{}) bool { return hi.(float64) > above })
+	ivs := make([]*Interval, len(elems))
+	for i, e := range elems {
+		ivs[i] = e.Value.(*Interval)
+	}
+	return ivs
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package interval
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+	s := New()
+	a, b, c := s.Insert(0, 10, "a"), s.Insert(5, 15, "b"), s.Insert(10, 20, "c")
+	for point, want := range map[float64]string{-1: "", 0: "a", 5: "ab", 9.5: "ab", 10: "bc", 19: "c", 20: ""} {
+		got := ""
+		for _, iv := range s.Stab(point) {
+			got += iv.Value.(string)
+		}
+		if got != want {
+			t.Error(point, got, want)
+		}
+	}
+	if got := s.Overlaps(10, 11); len(got) != 2 || got[0] != b || got[1] != c {
+		t.Error(got)
+	}
+	if got := s.Overlaps(-5, 0); 0 != len(got) || nil != s.Overlaps(3, 3) {
+		t.Error(got)
+	}
+	if !s.Remove(b) || s.Remove(b) || s.Len() != 2 {
+		t.Error("Remove")
+	}
+	if got := s.Stab(7); len(got) != 1 || got[0] != a {
+		t.Error(got)
+	}
+	defer func() {
+		if r := recover(); r != "interval: empty interval" {
+			t.Error(r)
+		}
+	}()
+	s.Insert(1, 1, nil)
+}
+
+func TestRandom(t *testing.T) {
+	t.Parallel()
+	s := New()
+	var all []*Interval
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		if 0 < len(all) && 0 == rng.Intn(3) {
+			j := rng.Intn(len(all))
+			s.Remove(all[j])
+			all = append(all[:j], all[j+1:]...)
+		} else {
+			lo := float64(rng.Intn(1000))
+			all = append(all, s.Insert(lo, lo+1+float64(rng.Intn(50)), nil))
+		}
+		lo := float64(rng.Intn(1100))
+		hi := lo + 1 + float64(rng.Intn(20))
+		want := 0
+		for _, iv := range all {
+			if iv.Lo < hi && lo < iv.Hi {
+				want++
+			}
+		}
+		if got := s.Overlaps(lo, hi); len(got) != want {
+			t.Fatal(i, lo, hi, len(got), want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package pqueue provides a priority queue, safe for concurrent use, whose
+// Pop blocks until an entry is available or its context is done, as for
+// the work queue of a job scheduler.  Entries are ordered by a skiplist,
+// so pushing and popping take O(log(N)) time.
+//
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"github.com/glenn-brown/skiplist"
+	"sync"
+)
+
+// ErrClosed is returned by Pop once the queue is closed and empty.
+//
+var ErrClosed = errors.New("pqueue: queue closed")
+
+// A Queue is a priority queue of values, popped lowest priority first, as
+// priorities are ordered by skiplist.New.  Values of equal priority are
+// popped in the order pushed.
+//
+type Queue struct {
+	closed  bool
+	l       *skiplist.T
+	mu      sync.Mutex
+	waiting int
+	wake    chan struct{}
+}
+
+// New returns a new, empty Queue.
+//
+func New() *Queue {
+	return &Queue{l: skiplist.New(), wake: make(chan struct{})}
+}
+
+// Len returns the number of values in the queue.
+//
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.l.Len()
+}
+
+// Push adds value to the queue with priority, waking any blocked Pop, in
+// O(log(N)) time.  It panics if the queue is closed.
+//
+func (q *Queue) Push(priority, value interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		panic("pqueue: push on closed queue")
+	}
+	q.l.Insert(priority, value)
+	if 0 < q.waiting {
+		close(q.wake)
+		q.wake = make(chan struct{})
+	}
+}
+
+// TryPop removes and returns the entry with the lowest priority in
+// O(log(N)) time, without blocking.  If the queue is empty, ok is false.
+//
+func (q *Queue) TryPop() (priority, value interface{}, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pop()
+}
+
+// Pop removes and returns the entry with the lowest priority, in
+// O(log(N)) time once one is available, blocking until then.  It returns
+// the context's error if ctx is done first, or ErrClosed if the queue is
+// closed and empty.
+//
+func (q *Queue) Pop(ctx context.Context) (priority, value interface{}, err error) {
+	q.mu.Lock()
+	for {
+		if priority, value, ok := q.pop(); ok {
+			q.mu.Unlock()
+			return priority, value, nil
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return nil, nil, ErrClosed
+		}
+		wake := q.wake
+		q.waiting++
+		q.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+		}
+		q.mu.Lock()
+		q.waiting--
+		if err := ctx.Err(); nil != err {
+			q.mu.Unlock()
+			return nil, nil, err
+		}
+	}
+}
+
+// Close closes the queue, so blocked and later Pops return ErrClosed once
+// the queue is empty.  Entries already pushed may still be popped.
+//
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.wake)
+	}
+}
+
+// Function pop removes and returns the oldest entry with the lowest
+// priority, if any.  The caller must hold the lock.
+//
+func (q *Queue) pop() (priority, value interface{}, ok bool) {
+	front := q.l.Front()
+	if nil == front {
+		return nil, nil, false
+	}
+	// Younger entries for a key come first, so the oldest is last.
+	e := q.l.RemoveN(q.l.PosFloor(front.Key()))
+	return e.Key(), e.Value, true
+}
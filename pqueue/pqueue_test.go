@@ -0,0 +1,76 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package pqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrder(t *testing.T) {
+	t.Parallel()
+	q := New()
+	if _, _, ok := q.TryPop(); ok {
+		t.Error("popped from empty queue")
+	}
+	for i, p := range []int{3, 1, 2, 1, 3} {
+		q.Push(p, i)
+	}
+	for _, want := range []int{1, 3, 2, 0, 4} {
+		if _, v, ok := q.TryPop(); !ok || v != want {
+			t.Error(v, ok, want)
+		}
+	}
+}
+
+func TestPop(t *testing.T) {
+	t.Parallel()
+	q := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := q.Pop(ctx); err != context.DeadlineExceeded {
+		t.Error(err)
+	}
+
+	// Many consumers each receive distinct values from many producers.
+
+	var wg sync.WaitGroup
+	got := make(chan interface{}, 1000)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				_, v, err := q.Pop(context.Background())
+				if err == ErrClosed {
+					return
+				}
+				got <- v
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		go q.Push(i%10, i)
+	}
+	seen := map[interface{}]bool{}
+	for len(seen) < 1000 {
+		v := <-got
+		if seen[v] {
+			t.Fatal("popped twice", v)
+		}
+		seen[v] = true
+	}
+	q.Close()
+	wg.Wait()
+	if q.Len() != 0 {
+		t.Error(q.Len())
+	}
+	defer func() {
+		if r := recover(); r != "pqueue: push on closed queue" {
+			t.Error(r)
+		}
+	}()
+	q.Push(0, 0)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package quantiles tracks percentiles of a stream of observations, such
+// as request latencies, over a skiplist of the observations.  Every
+// observation may be kept, for exact percentiles, or a bounded sample,
+// optionally weighted toward recent observations with exponential decay.
+//
+package quantiles
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// A Tracker holds observations, or a sample of them, and reports their
+// percentiles.  Like a skiplist, it is not safe for concurrent use.
+//
+type Tracker struct {
+	alpha    float64
+	byWeight *skiplist.T
+	now      func() time.Time
+	rng      *rand.Rand
+	size     int
+	start    time.Time
+	values   *skiplist.T
+}
+
+// New returns a new, empty Tracker.  If size is 0, every observation is
+// kept.  Otherwise a sample of size observations is kept, and alpha sets
+// how strongly the sample favors recent observations: an observation
+// made t seconds after another is exp(alpha*t) times as likely to be kept.
+// An alpha of 0 keeps a uniform sample of all observations since the last
+// Reset.
+//
+func New(size int, alpha float64) *Tracker {
+	z := &Tracker{alpha: alpha, now: time.Now, rng: rand.New(rand.NewPCG(42, 42)), size: size}
+	return z.Reset()
+}
+
+// Len returns the number of observations held in O(1) time.
+//
+func (z *Tracker) Len() int { return z.values.Len() }
+
+// Observe records observation v in O(log(N)) time.  It panics if v is NaN.
+//
+func (z *Tracker) Observe(v float64) {
+	if math.IsNaN(v) {
+		panic("quantiles: NaN observation")
+	}
+	if 0 == z.size {
+		z.values.Insert(v, nil)
+		return
+	}
+
+	// Forward-decay priority sampling keeps the observations with the
+	// greatest weights, exp(alpha*t)/u for uniform u, compared as logs
+	// so they never overflow.
+
+	w := z.alpha*z.now().Sub(z.start).Seconds() - math.Log(1-z.rng.Float64())
+	if z.byWeight.Len() == z.size {
+		if w <= z.byWeight.Front().Key().(float64) {
+			return
+		}
+		z.values.RemoveElement(z.byWeight.RemoveN(0).Value.(*skiplist.Element))
+	}
+	z.byWeight.Insert(w, z.values.InsertElem(v, nil))
+}
+
+// P returns the observation at quantile q, from 0 to 1, of those held, by
+// the nearest-rank method, in O(log(N)) time.  For example, P(0.99) is the
+// 99th percentile.  P returns NaN if there are no observations, and panics
+// unless 0 <= q <= 1.
+//
+func (z *Tracker) P(q float64) float64 {
+	if !(0 <= q && q <= 1) {
+		panic("quantiles: quantile out of range [0,1]")
+	}
+	if e := z.values.Quantile(q); nil != e {
+		return e.Key().(float64)
+	}
+	return math.NaN()
+}
+
+// Reset discards all observations, restarting any decay from now, and
+// returns the Tracker.
+//
+func (z *Tracker) Reset() *Tracker {
+	z.byWeight, z.values, z.start = skiplist.New(), skiplist.New(), z.now()
+	return z
+}
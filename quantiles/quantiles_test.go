@@ -0,0 +1,67 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package quantiles
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestExact(t *testing.T) {
+	t.Parallel()
+	z := New(0, 0)
+	if !math.IsNaN(z.P(0.5)) {
+		t.Error("empty tracker")
+	}
+	for _, i := range rand.Perm(1000) {
+		z.Observe(float64(i + 1))
+	}
+	for q, want := range map[float64]float64{0: 1, 0.5: 500, 0.99: 990, 1: 1000} {
+		if p := z.P(q); p != want {
+			t.Error(q, p, want)
+		}
+	}
+	if z.Reset().Len() != 0 {
+		t.Error("Reset")
+	}
+	defer func() {
+		if r := recover(); r != "quantiles: NaN observation" {
+			t.Error(r)
+		}
+	}()
+	z.Observe(math.NaN())
+}
+
+func TestSample(t *testing.T) {
+	t.Parallel()
+	z := New(500, 0)
+	for i := 0; i < 100000; i++ {
+		z.Observe(float64(i % 1000))
+	}
+	if z.Len() != 500 {
+		t.Error(z.Len())
+	}
+	if p := z.P(0.5); p < 400 || p > 600 {
+		t.Error(p)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(0, 0)
+	z := New(100, 1)
+	z.now = func() time.Time { return now }
+	z.Reset()
+	for i := 0; i < 1000; i++ {
+		z.Observe(1)
+	}
+	now = now.Add(time.Minute)
+	for i := 0; i < 1000; i++ {
+		z.Observe(2)
+	}
+	if p := z.P(0); p != 2 {
+		t.Error("old observations outweighed recent ones", p)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package queue provides a strict FIFO queue over a skiplist keyed by
+// sequence number.  Unlike a slice or ring buffer, the queue supports
+// inspecting any position and cancelling any entry in O(log(N)) time.
+//
+package queue
+
+import (
+	"github.com/glenn-brown/skiplist"
+)
+
+// A Queue is a FIFO queue of values, each identified by the sequence
+// number assigned when it was enqueued.
+//
+type Queue struct {
+	l   *skiplist.T
+	seq int
+}
+
+// New returns a new, empty Queue.
+//
+func New() *Queue {
+	return &Queue{l: skiplist.New()}
+}
+
+// Len returns the number of values in the queue in O(1) time.
+//
+func (q *Queue) Len() int { return q.l.Len() }
+
+// Enqueue adds v to the back of the queue in O(log(N)) time, returning the
+// sequence number that identifies it for Cancel.
+//
+func (q *Queue) Enqueue(v interface{}) (seq int) {
+	seq = q.seq
+	q.seq++
+	q.l.Insert(seq, v)
+	return seq
+}
+
+// Dequeue removes and returns the value at the front of the queue, in
+// O(log(N)) time.  If the queue is empty, ok is false.
+//
+func (q *Queue) Dequeue() (v interface{}, ok bool) {
+	if e := q.l.RemoveN(0); nil != e {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// PeekAt returns the value at position i of the queue, where the front is
+// position 0, without removing it, in O(log(i)) time.  If there is no such
+// position, ok is false.
+//
+func (q *Queue) PeekAt(i int) (v interface{}, ok bool) {
+	if i < 0 {
+		return nil, false
+	}
+	if e := q.l.ElementN(i); nil != e {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// Cancel removes the value enqueued with sequence number seq, wherever it
+// is in the queue, in O(log(N)) time, and reports whether it was present.
+//
+func (q *Queue) Cancel(seq int) bool {
+	return nil != q.l.Remove(seq)
+}
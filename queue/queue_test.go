@@ -0,0 +1,37 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package queue
+
+import (
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+	q := New()
+	if _, ok := q.Dequeue(); ok {
+		t.Error("dequeued from empty queue")
+	}
+	for i := 0; i < 10; i++ {
+		if q.Enqueue(i*10) != i {
+			t.Fatal("unexpected sequence number")
+		}
+	}
+	if !q.Cancel(0) || !q.Cancel(5) || q.Cancel(5) || q.Len() != 8 {
+		t.Error("cancel")
+	}
+	if v, ok := q.PeekAt(4); !ok || v != 60 {
+		t.Error(v, ok)
+	}
+	if _, ok := q.PeekAt(8); ok {
+		t.Error("peeked past back")
+	}
+	for _, want := range []int{10, 20, 30, 40, 60, 70, 80, 90} {
+		if v, ok := q.Dequeue(); !ok || v != want {
+			t.Error(v, ok, want)
+		}
+	}
+	if q.Len() != 0 {
+		t.Error(q.Len())
+	}
+}
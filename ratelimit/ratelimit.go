@@ -0,0 +1,53 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package ratelimit provides a sliding-window rate limiter, which allows
+// at most a fixed number of events in any window of time.  Event times are
+// kept in a skiplist, so counting the events in the window takes
+// O(log(N)) time, and forgetting old events O(log(N)) time plus O(1) per
+// event.
+//
+package ratelimit
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"math"
+	"time"
+)
+
+// A Limiter allows at most a number of events per window.  Like a
+// skiplist, it is not safe for concurrent use.
+//
+type Limiter struct {
+	events *skiplist.T
+	limit  int
+	window time.Duration
+}
+
+// New returns a Limiter allowing at most limit events in any window of
+// the given length.
+//
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{skiplist.New(), limit, window}
+}
+
+// Allow reports whether an event at time now is allowed, and records it
+// if so.  Events more than a window before now are forgotten, so times
+// should not go backward by more than a window.
+//
+func (r *Limiter) Allow(now time.Time) bool {
+	if r.Count(now) >= r.limit {
+		return false
+	}
+	r.events.Insert(now.UnixNano(), nil)
+	return true
+}
+
+// Count returns the number of allowed events in the window ending at now,
+// (now-window, now], forgetting older events.
+//
+func (r *Limiter) Count(now time.Time) int {
+	t := now.UnixNano()
+	start := t - int64(r.window)
+	r.events.RemoveRange(int64(math.MinInt64), start+1)
+	return r.events.CountRange(start+1, t+1)
+}
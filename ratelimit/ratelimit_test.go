@@ -0,0 +1,31 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+	r := New(3, time.Second)
+	at := time.Unix(100, 0)
+	allowed := ""
+	for i := 0; i < 12; i++ {
+		if r.Allow(at.Add(time.Duration(i) * 250 * time.Millisecond)) {
+			allowed += "y"
+		} else {
+			allowed += "n"
+		}
+	}
+	if allowed != "yyynyyynyyyn" {
+		t.Error(allowed)
+	}
+	if n := r.Count(at.Add(3 * time.Second)); n != 2 {
+		t.Error(n)
+	}
+	if n := r.Count(at.Add(time.Hour)); n != 0 || r.events.Len() != 0 {
+		t.Error(n, r.events.Len())
+	}
+}
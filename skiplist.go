@@ -7,7 +7,8 @@
 // It can act as a map or as a multimap.
 // It automatically adjusts its depth.
 // It mimics Go's container/list interface where possible.
-// It automatically and efficiently supports int*, float*, uint*, string, and []byte keys.
+// It automatically and efficiently supports int*, float*, uint*, string, and []byte keys,
+// *big.Int and *big.Rat keys, and netip.Addr, netip.Prefix and net.IP keys.
 // It supports externally defined key types via the FastKey and SlowKey interfaces.
 //
 // Get, Set, Insert, Remove*, Element*, and Pos operations all require
@@ -19,9 +20,26 @@ package skiplist
 
 import (
 	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/glenn-brown/ordinal"
-	"math/rand"
+	"maps"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // A skiplist.T is a skiplist.  A skiplist is linked at multiple
@@ -46,14 +64,58 @@ import (
 // each level.	
 //
 type T struct {
-	cnt   int
-	less  func(a, b interface{}) bool
-	links []link
-	prev  []prev
-	rng   *rand.Rand
-	score func(a interface{}) float64
+	agg       *aggregator
+	bloom     *bloom
+	bytewise  bool
+	clock     func() time.Time
+	cnt       int
+	expiry    *T
+	finger    *finger
+	fns       func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64)
+	guard     bool
+	hash      func(value interface{}) uint64
+	keys      map[interface{}]*Element
+	labels    map[string]string
+	less      func(a, b interface{}) bool
+	links     []link
+	maxLen    int
+	maxLevel  int
+	name      string
+	nans      NaNPolicy
+	nils      NilPolicy
+	onInsert  func(e *Element)
+	onRemove  func(e *Element)
+	onReplace func(old, nu *Element)
+	oplog     *oplog
+	owner     uint64
+	p         float64
+	prev      []prev
+	reversed  bool
+	rng       Source
+	sample    interface{}
+	score     func(a interface{}) float64
+	seq       uint64
+	strict    bool
+	tracked   []threshold
+	ttls      map[*Element]*Element
+	unscored  bool
+	values    map[uint64][]*Element
+	watches   *T
+}
+
+// A Source supplies the random bits from which a list chooses element
+// levels.  It is satisfied by the sources and *Rand of math/rand/v2.
+//
+type Source interface {
+	Uint64() uint64
+}
+
+type threshold struct {
+	score float64
+	below int
 }
 type link struct {
+	agg   interface{}
 	to    *Element
 	width int
 }
@@ -66,6 +128,7 @@ type Element struct {
 	Value interface{}
 	score float64
 	links []link
+	seq   uint64
 }
 
 // Key returns the key used to insert the value in the list element in O(1) time.
@@ -78,322 +141,4238 @@ func (e *Element) Next() *Element { return e.links[0].to }
 
 // String returns a Key:Value string representation of the element.
 //
-func (e *Element) String() string { return fmt.Sprintf("%v:%v", e.key, e.Value) }
+func (e *Element) String() string { return fmt.Sprintf("%v:%v", e.key, e.Value) }
+
+// New returns a new skiplist in O(1) time.
+// The list will be sorted from least to greatest key.
+//
+func New() *T {
+	l := newT(keyFns, false)
+	l.bytewise = true
+	return l
+}
+
+// NewDescending is like New, except keys are sorted from greatest to least.
+// Its comparisons are exactly those of New reversed, and its scores those
+// of New negated.
+//
+func NewDescending() *T {
+	l := newT(func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		less, score := keyFns(key)
+		return func(a, b interface{}) bool { return less(b, a) }, func(a interface{}) float64 { return -score(a) }
+	}, true)
+	l.bytewise = true
+	return l
+}
+
+// Function keyFns returns the less and score functions ordering keys of
+// the type of key from least to greatest.  Keys with a Score method
+// taking the key, as in the FastKey interface of package ordinal, are
+// scored by it.  *big.Int and *big.Rat keys score as the nearest float64,
+// which is infinite for those beyond its range.  netip.Addr keys are
+// ordered as by Addr.Less, and netip.Prefix keys by address and then by
+// length, so a prefix precedes the longer prefixes it contains.  net.IP
+// keys are ordered by their 16-byte forms, so IPv4 addresses in either
+// form are equal.  Addresses score by their high 64 bits.
+//
+func keyFns(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+	switch key.(type) {
+	case FastKey:
+		return lessFn(key), func(a interface{}) float64 { return a.(FastKey).Score() }
+	case ordinalFastKey:
+		return lessFn(key), func(a interface{}) float64 { return a.(ordinalFastKey).Score(a) }
+	case SlowKey:
+		return lessFn(key), func(a interface{}) float64 { return 0 }
+	case *big.Int:
+		return func(a, b interface{}) bool { return a.(*big.Int).Cmp(b.(*big.Int)) < 0 },
+			func(a interface{}) float64 { f, _ := a.(*big.Int).Float64(); return f }
+	case *big.Rat:
+		return func(a, b interface{}) bool { return a.(*big.Rat).Cmp(b.(*big.Rat)) < 0 },
+			func(a interface{}) float64 { f, _ := a.(*big.Rat).Float64(); return f }
+	case netip.Addr:
+		return func(a, b interface{}) bool { return a.(netip.Addr).Less(b.(netip.Addr)) },
+			func(a interface{}) float64 { return addrScore(a.(netip.Addr)) }
+	case netip.Prefix:
+		return func(a, b interface{}) bool {
+				p, q := a.(netip.Prefix), b.(netip.Prefix)
+				if p.Addr() != q.Addr() {
+					return p.Addr().Less(q.Addr())
+				}
+				return p.Bits() < q.Bits()
+			},
+			func(a interface{}) float64 { return addrScore(a.(netip.Prefix).Addr()) }
+	case net.IP:
+		return func(a, b interface{}) bool { return bytes.Compare(a.(net.IP).To16(), b.(net.IP).To16()) < 0 },
+			func(a interface{}) float64 {
+				var hi [8]byte
+				copy(hi[:], a.(net.IP).To16())
+				return float64(binary.BigEndian.Uint64(hi[:]))
+			}
+	case TupleKey:
+		return tupleFns()
+	}
+	less, score := ordinal.Fns(key)
+	return less, wideScore(key, score)
+}
+
+// A TupleKey is a composite key, made by Tuple.
+//
+type TupleKey []interface{}
+
+// Tuple returns a composite key of elems, for ordering by several columns.
+// Tuples are ordered element by element, each by the rules New uses for
+// keys of its type, and a tuple precedes the longer tuples it begins.
+// Elements at the same position in the tuples of a list must have the
+// same type.
+//
+func Tuple(elems ...interface{}) TupleKey { return elems }
+
+// MapKey returns a comparable value standing for the tuple, for use as a
+// map key, as by ToMap and WithKeyIndex.  Tuples with equal elements give
+// equal values, with []byte and net.IP elements converted as by ToMap.  It
+// panics if an element is of another type that cannot be a map key.
+//
+func (t TupleKey) MapKey() interface{} {
+	var k interface{} = tupleMapKey{}
+	for i := len(t) - 1; i >= 0; i-- {
+		k = tupleMapKey{mapKey(t[i]), k}
+	}
+	return k
+}
+
+// A tupleMapKey is a comparable form of a TupleKey, as a chain of its
+// elements ending with the zero tupleMapKey.
+//
+type tupleMapKey struct {
+	head interface{}
+	tail interface{}
+}
+
+// Function tupleFns returns less and score functions for TupleKey keys.
+// The functions for each position are inferred from the first element
+// seen there.  Tuples score by their first elements.
+//
+func tupleFns() (func(a, b interface{}) bool, func(a interface{}) float64) {
+	var lesses []func(a, b interface{}) bool
+	var score func(a interface{}) float64
+	at := func(i int, elem interface{}) func(a, b interface{}) bool {
+		if i == len(lesses) {
+			less, s := keyFns(elem)
+			lesses = append(lesses, less)
+			if 0 == i {
+				score = s
+			}
+		}
+		return lesses[i]
+	}
+	return func(a, b interface{}) bool {
+			x, y := a.(TupleKey), b.(TupleKey)
+			for i := 0; i < len(x) && i < len(y); i++ {
+				less := at(i, x[i])
+				if less(x[i], y[i]) {
+					return true
+				}
+				if less(y[i], x[i]) {
+					return false
+				}
+			}
+			return len(x) < len(y)
+		}, func(a interface{}) float64 {
+			x := a.(TupleKey)
+			if 0 == len(x) {
+				return math.Inf(-1)
+			}
+			at(0, x[0])
+			return score(x[0])
+		}
+}
+
+// Function addrScore returns a score for a, in the order of Addr.Less:
+// the invalid address, then IPv4 addresses, then IPv6 addresses by their
+// high 64 bits.
+//
+func addrScore(a netip.Addr) float64 {
+	switch {
+	case a.Is4():
+		b := a.As4()
+		return float64(binary.BigEndian.Uint32(b[:]))
+	case a.Is6():
+		b := a.As16()
+		return 1<<33 + float64(binary.BigEndian.Uint64(b[:8]))
+	}
+	return -1
+}
+
+// An ordinalFastKey is a FastKey whose Score method takes the key.
+//
+type ordinalFastKey interface {
+	Less(interface{}) bool
+	Score(interface{}) float64
+}
+
+// Function wideScore returns a score function for keys of the type of key
+// if that is an integer type too wide for float64 to represent exactly, or
+// score otherwise.  Converting such keys to float64 rounds them, but never
+// reorders them, so keys too close to score differently tie and are
+// ordered by less.
+//
+func wideScore(key interface{}, score func(a interface{}) float64) func(a interface{}) float64 {
+	switch key.(type) {
+	case int:
+		return func(a interface{}) float64 { return float64(a.(int)) }
+	case int64:
+		return func(a interface{}) float64 { return float64(a.(int64)) }
+	case uint:
+		return func(a interface{}) float64 { return float64(a.(uint)) }
+	case uint64:
+		return func(a interface{}) float64 { return float64(a.(uint64)) }
+	case uintptr:
+		return func(a interface{}) float64 { return float64(a.(uintptr)) }
+	}
+	return score
+}
+
+// WithScorePrefix makes the list score string and []byte keys beginning
+// with prefix by the bytes following it, and returns the list.  Scores
+// are otherwise taken from the first bytes of a key, so keys sharing a
+// long prefix, such as URLs or paths, all score alike and are ordered by
+// slower comparisons.  Keys without the prefix remain correctly ordered,
+// but all score alike.  It must be called before any key is inserted,
+// and only for lists ordering keys bytewise, as New and NewDescending do.
+//
+func (l *T) WithScorePrefix(prefix string) *T {
+	if 0 != l.cnt {
+		l.panicf("WithScorePrefix called on a list with %d entries", l.cnt)
+	}
+	fns, sign := l.fns, 1.0
+	if l.reversed {
+		sign = -1
+	}
+	l.fns = func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		less, score := fns(key)
+		switch key.(type) {
+		case string:
+			score = func(a interface{}) float64 { return sign * prefixScore(prefix, a.(string)) }
+		case []byte:
+			score = func(a interface{}) float64 { return sign * prefixScore(prefix, string(a.([]byte))) }
+		}
+		return less, score
+	}
+	l.lazy()
+	return l
+}
+
+// WithoutScores makes the list order keys by their less functions alone,
+// never computing scores, and returns the list.  This suits key types
+// whose Score is expensive or cannot be trusted to agree with Less.  It
+// must be called before any key is inserted.
+//
+func (l *T) WithoutScores() *T {
+	if 0 != l.cnt {
+		l.panicf("WithoutScores called on a list with %d entries", l.cnt)
+	}
+	fns := l.fns
+	l.fns = func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		less, _ := fns(key)
+		return less, func(a interface{}) float64 { return 0 }
+	}
+	l.unscored = true
+	l.lazy()
+	return l
+}
+
+// FoldCase makes the list order string keys without regard to case, as
+// directory listings do, and returns the list.  Keys differing only in
+// case remain distinct, ordered by byte value.  It must be called before
+// any key is inserted.
+//
+func (l *T) FoldCase() *T {
+	return l.orderStrings("FoldCase", foldCompare, foldScore)
+}
+
+// NaturalOrder makes the list order string keys naturally, comparing runs
+// of decimal digits by their numeric values, so "file2" precedes
+// "file10", and returns the list.  Keys differing only in leading zeros
+// remain distinct, ordered by byte value.  It must be called before any
+// key is inserted.
+//
+func (l *T) NaturalOrder() *T {
+	return l.orderStrings("NaturalOrder", naturalCompare, naturalScore)
+}
+
+// ReflectKeys makes the list order struct keys of types it does not
+// otherwise support field by field, in declaration order, using
+// reflection, and returns the list.  Fields must be integers, floats,
+// strings, bools (false first), or arrays or structs of these.  Such keys
+// all score alike, so each comparison uses reflection; keys implementing
+// SlowKey or FastKey are faster.  It must be called before any key is
+// inserted.
+//
+func (l *T) ReflectKeys() *T {
+	if 0 != l.cnt {
+		l.panicf("ReflectKeys called on a list with %d entries", l.cnt)
+	}
+	fns, reversed := l.fns, l.reversed
+	l.fns = func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		typ := reflect.TypeOf(key)
+		if _, ok := key.(SlowKey); ok || nil == typ || reflect.Struct != typ.Kind() {
+			return fns(key)
+		}
+		compare := reflectCompare(typ)
+		if nil == compare {
+			l.panicf("%T has fields that cannot be ordered", key)
+		}
+		less := func(a, b interface{}) bool { return compare(reflect.ValueOf(a), reflect.ValueOf(b)) < 0 }
+		if reversed {
+			less = func(a, b interface{}) bool { return compare(reflect.ValueOf(b), reflect.ValueOf(a)) < 0 }
+		}
+		return less, func(a interface{}) float64 { return 0 }
+	}
+	l.lazy()
+	return l
+}
+
+// Function reflectCompare returns a function comparing values of type t,
+// returning -1, 0 or 1, or nil if t cannot be ordered.
+//
+func reflectCompare(t reflect.Type) func(a, b reflect.Value) int {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b reflect.Value) int { return cmp.Compare(a.Int(), b.Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b reflect.Value) int { return cmp.Compare(a.Uint(), b.Uint()) }
+	case reflect.Float32, reflect.Float64:
+		return func(a, b reflect.Value) int { return cmp.Compare(a.Float(), b.Float()) }
+	case reflect.String:
+		return func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) }
+	case reflect.Bool:
+		return func(a, b reflect.Value) int {
+			switch x, y := a.Bool(), b.Bool(); {
+			case x == y:
+				return 0
+			case y:
+				return -1
+			}
+			return 1
+		}
+	case reflect.Array:
+		elem := reflectCompare(t.Elem())
+		if nil == elem {
+			return nil
+		}
+		return func(a, b reflect.Value) int {
+			for i := 0; i < a.Len(); i++ {
+				if c := elem(a.Index(i), b.Index(i)); 0 != c {
+					return c
+				}
+			}
+			return 0
+		}
+	case reflect.Struct:
+		fields := make([]func(a, b reflect.Value) int, t.NumField())
+		for i := range fields {
+			if fields[i] = reflectCompare(t.Field(i).Type); nil == fields[i] {
+				return nil
+			}
+		}
+		return func(a, b reflect.Value) int {
+			for i, field := range fields {
+				if c := field(a.Field(i), b.Field(i)); 0 != c {
+					return c
+				}
+			}
+			return 0
+		}
+	}
+	return nil
+}
+
+// Function orderStrings makes the list order string keys by compare,
+// breaking ties by byte value, and score them by score, for the method
+// named.  Other keys are ordered as before.
+//
+func (l *T) orderStrings(method string, compare func(a, b string) int, score func(key string) float64) *T {
+	if 0 != l.cnt {
+		l.panicf("%s called on a list with %d entries", method, l.cnt)
+	}
+	fns, sign := l.fns, 1.0
+	less := func(a, b interface{}) bool {
+		x, y := a.(string), b.(string)
+		if c := compare(x, y); 0 != c {
+			return c < 0
+		}
+		return x < y
+	}
+	if l.reversed {
+		forward := less
+		less, sign = func(a, b interface{}) bool { return forward(b, a) }, -1
+	}
+	l.fns = func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		if _, ok := key.(string); !ok {
+			return fns(key)
+		}
+		return less, func(a interface{}) float64 { return sign * score(a.(string)) }
+	}
+	l.bytewise = false
+	l.lazy()
+	return l
+}
+
+// Function foldCompare compares a and b by their lower-case runes,
+// returning -1, 0 or 1.
+//
+func foldCompare(a, b string) int {
+	for a != "" && b != "" {
+		r, n := utf8.DecodeRuneInString(a)
+		s, m := utf8.DecodeRuneInString(b)
+		if r, s = unicode.ToLower(r), unicode.ToLower(s); r != s {
+			if r < s {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[n:], b[m:]
+	}
+	switch {
+	case a != "":
+		return 1
+	case b != "":
+		return -1
+	}
+	return 0
+}
+
+// Function foldScore returns a score for key from the first 8 bytes of its
+// lower-case form, in the order of foldCompare.
+//
+func foldScore(key string) float64 {
+	buf := make([]byte, 0, 12)
+	for _, r := range key {
+		if len(buf) >= 8 {
+			break
+		}
+		buf = utf8.AppendRune(buf, unicode.ToLower(r))
+	}
+	var hi [8]byte
+	copy(hi[:], buf)
+	return float64(binary.BigEndian.Uint64(hi[:]))
+}
+
+// Function naturalCompare compares a and b naturally, returning -1, 0 or
+// 1.  Runs of digits in both at the same place compare by value; other
+// bytes compare by value.
+//
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			i, j := 1, 1
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x, y := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if len(x) != len(y) {
+				if len(x) < len(y) {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(x, y); 0 != c {
+				return c
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	switch {
+	case a != "":
+		return 1
+	case b != "":
+		return -1
+	}
+	return 0
+}
+
+// Function naturalScore returns a score for key, in the order of
+// naturalCompare, from its first 8 bytes up to its first digit, which
+// scores as '0'.
+//
+func naturalScore(key string) float64 {
+	var hi [8]byte
+	for i := 0; i < len(key) && i < len(hi); i++ {
+		if isDigit(key[i]) {
+			hi[i] = '0'
+			break
+		}
+		hi[i] = key[i]
+	}
+	return float64(binary.BigEndian.Uint64(hi[:]))
+}
+
+// Function isDigit returns true iff c is an ASCII decimal digit.
+//
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// Function prefixScore returns a score for key, in order with all other
+// keys: 0 for keys before prefix, 3 for keys after all those beginning
+// with prefix, and between 1 and 2 for keys beginning with prefix, by the
+// first 8 bytes following it.
+//
+func prefixScore(prefix, key string) float64 {
+	if !strings.HasPrefix(key, prefix) {
+		if key < prefix {
+			return 0
+		}
+		return 3
+	}
+	var u uint64
+	for i := 0; i < 8; i++ {
+		u <<= 8
+		if j := len(prefix) + i; j < len(key) {
+			u |= uint64(key[j])
+		}
+	}
+	return 1 + float64(u)/(1<<64)
+}
+
+// NewFunc returns a new skiplist in O(1) time, sorted by the comparison
+// function less rather than one inferred from the key type.  This allows,
+// for example, case-insensitive ordering of strings without wrapping each
+// key in a SlowKey.  All keys score alike, so RangeByScore panics on the
+// list; use NewWithScore for that.
+//
+func NewFunc(less func(a, b interface{}) bool) *T {
+	l := NewWithScore(less, func(a interface{}) float64 { return 0 })
+	l.unscored = true
+	return l
+}
+
+// NewWithScore is like NewFunc, but also takes a score function, which
+// must be monotonic in the order given by less: if less(a, b), then
+// score(a) <= score(b).  Comparisons are then made by score, falling back
+// to less only for equal scores, as for keys implementing FastKey.
+//
+func NewWithScore(less func(a, b interface{}) bool, score func(a interface{}) float64) *T {
+	return newT(func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		return less, score
+	}, false)
+}
+
+// NewWithCapacity is like New, but preallocates the level structures for
+// n elements, as by WithSizeHint, and caps element levels at those needed
+// for n elements, even if the list grows larger.
+//
+func NewWithCapacity(n int) *T {
+	l := New().WithSizeHint(n)
+	l.maxLevel = levelsFor(n)
+	return l
+}
+
+// Function newT returns a new skiplist that will use fns to infer its
+// comparison and score functions from the first key it sees.
+//
+func newT(fns func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64), reversed bool) *T {
+	nu := &T{fns: fns, reversed: reversed}
+
+	// Seed a private random number generator for reproducibility.
+
+	nu.rng = rand.NewPCG(42, 42)
+	nu.lazy()
+	return nu
+}
+
+// Function lazy arranges to set l.less and l.score the first time either is called.
+// We can't do it sooner because we can't infer the key type until the first
+// key is inserted.
+//
+func (l *T) lazy() {
+	l.sample = nil
+	l.less = func(a, b interface{}) bool {
+		if NilFirst == l.nils && (nil == a || nil == b) {
+			return nil == a && nil != b
+		}
+		l.infer(a)
+		return l.less(a, b)
+	}
+	l.score = func(a interface{}) float64 {
+		if NilFirst == l.nils && nil == a {
+			return math.Inf(-1)
+		}
+		l.infer(a)
+		return l.score(a)
+	}
+}
+
+// Function infer sets l.less and l.score from key, applying the NaN and nil
+// key policies.
+//
+func (l *T) infer(key interface{}) {
+	l.less, l.score = l.fns(key)
+	l.sample = key
+	if NaNFirst == l.nans || NaNLast == l.nans {
+		l.less, l.score = nanAt(NaNLast == l.nans, l.less, l.score)
+	}
+	if NilFirst == l.nils {
+		l.less, l.score = nilFirst(l.less, l.score)
+	}
+}
+
+// Function empty returns a new, empty skiplist ordered like l.
+//
+func (l *T) empty() *T {
+	nu := &T{bytewise: l.bytewise, clock: l.clock, fns: l.fns, labels: maps.Clone(l.labels), less: l.less, maxLen: l.maxLen, maxLevel: l.maxLevel, name: l.name, p: l.p, nans: l.nans, nils: l.nils, score: l.score, reversed: l.reversed, strict: l.strict, unscored: l.unscored}
+	nu.rng = rand.NewPCG(42, 42)
+	if nil != l.fns {
+		// Don't share l's lazy functions, which would update l.
+		nu.lazy()
+	}
+	return nu
+}
+
+// Named attaches a name and labels to the list, for telling lists apart in
+// diagnostics such as panic messages.  Copies of the list keep them.  The
+// labels are copied, so later changes to the map do not affect the list.
+//
+func (l *T) Named(name string, labels map[string]string) *T {
+	l.name, l.labels = name, maps.Clone(labels)
+	return l
+}
+
+// Name returns the name attached to the list by Named, if any.
+//
+func (l *T) Name() string { return l.name }
+
+// Labels returns the labels attached to the list by Named, if any.
+//
+func (l *T) Labels() map[string]string { return l.labels }
+
+// WithSizeHint preallocates the level structures of the list for n
+// elements, so that the list need not reallocate them as it grows to that
+// size, and returns the list.  Levels are still added and removed as the
+// length of the list passes each power of two, so the hint neither sets a
+// starting level nor caps levels; NewWithCapacity also caps them.
+//
+func (l *T) WithSizeHint(n int) *T {
+	if levels := levelsFor(n); levels > cap(l.links) {
+		l.links = append(make([]link, 0, levels), l.links...)
+		l.prev = append(make([]prev, 0, levels), l.prev...)
+	}
+	return l
+}
+
+// WithSeed reseeds the PCG generator that chooses element levels, and
+// returns the list.  By default every list uses the same seed, which makes
+// level structure reproducible but correlated across lists.
+//
+func (l *T) WithSeed(seed int64) *T {
+	l.rng = rand.NewPCG(uint64(seed), 42)
+	return l
+}
+
+// WithRand makes the list choose element levels using rng, and returns
+// the list.  The list uses rng without locking, so rng must not be used
+// concurrently elsewhere.
+//
+func (l *T) WithRand(rng *rand.Rand) *T {
+	return l.WithSource(rng)
+}
+
+// WithProbability sets the probability p with which an element is promoted
+// from each level to the next, which is 1/2 by default, and returns the
+// list.  A lower p, such as 1/4, uses less memory per element at a small
+// cost in search time.  It panics unless 0 < p < 1.
+//
+func (l *T) WithProbability(p float64) *T {
+	if !(0 < p && p < 1) {
+		l.panicf("WithProbability %v is not between 0 and 1", p)
+	}
+	l.p = p
+	if 0.5 == p {
+		l.p = 0
+	}
+	return l
+}
+
+// WithSource makes the list choose element levels using src, and returns
+// the list.  Like WithRand, src is used without locking.
+//
+func (l *T) WithSource(src Source) *T {
+	l.rng = src
+	return l
+}
+
+// CheckOwner makes the list panic if it is modified by any goroutine
+// other than the first to modify it after the call, and returns the list.
+// The plain list is not safe for concurrent use, and this debugging aid
+// catches the mistake of sharing it without a lock.  Identifying the
+// goroutine makes each modification noticeably slower.  Call CheckOwner
+// again to hand the list to another goroutine.
+//
+func (l *T) CheckOwner() *T {
+	l.guard, l.owner = true, 0
+	return l
+}
+
+// WithValueIndex makes the list maintain an index from values, as hashed by
+// hash, to the elements holding them, and returns the list.  ContainsValue
+// and RemoveByValue then need not scan the list.  While indexed, values
+// must only be changed through the methods of the list, not by assigning
+// Element.Value directly.  Building the index takes O(N) time.
+//
+func (l *T) WithValueIndex(hash func(value interface{}) uint64) *T {
+	l.hash, l.values = hash, map[uint64][]*Element{}
+	for e := l.Front(); nil != e; e = e.Next() {
+		l.index(e)
+	}
+	return l
+}
+
+// ContainsValue returns true iff some entry of the list has a value equal
+// to v, in O(1) expected time if the list has a value index, and O(N) time
+// otherwise.  The values are compared with ==.
+//
+func (l *T) ContainsValue(v interface{}) bool {
+	return nil != l.elementByValue(v)
+}
+
+// RemoveByValue removes an entry whose value equals v, if any, returning
+// its Element or nil.  With a value index, O(log(N)) expected time is
+// required, plus O(M) time if the entry is one of M entries for its key;
+// otherwise O(N) time is required.
+//
+func (l *T) RemoveByValue(v interface{}) *Element {
+	if e := l.elementByValue(v); nil != e {
+		return l.RemoveElement(e)
+	}
+	return nil
+}
+
+// Function elementByValue returns an element whose value equals v, or nil.
+//
+func (l *T) elementByValue(v interface{}) *Element {
+	if nil != l.hash {
+		for _, e := range l.values[l.hash(v)] {
+			if e.Value == v {
+				return e
+			}
+		}
+		return nil
+	}
+	for e := l.Front(); nil != e; e = e.Next() {
+		if e.Value == v {
+			return e
+		}
+	}
+	return nil
+}
+
+// Clone returns an independent copy of the list, with the same ordering,
+// contents, and level structure, in O(N) time.  Values are copied
+// shallowly.
+//
+func (l *T) Clone() *T {
+	c := l.empty()
+	c.cnt = l.cnt
+	c.links = make([]link, len(l.links))
+	c.prev = make([]prev, len(l.prev))
+	c.seq = l.seq
+	c.tracked = append([]threshold{}, l.tracked...)
+
+	// Copy the elements, then mirror the links at each level.
+
+	elems := make([]*Element, 0, l.cnt)
+	for e := l.Front(); nil != e; e = e.Next() {
+		elems = append(elems, &Element{e.key, e.Value, e.score, make([]link, len(e.links)), e.seq})
+	}
+	for level := range l.links {
+		from, to, pos := &l.links[level], &c.links[level], -1
+		for ; nil != from.to; from, to = &from.to.links[level], &elems[pos].links[level] {
+			pos += from.width
+			to.to, to.width = elems[pos], from.width
+		}
+		to.width = from.width
+	}
+	if nil != l.hash {
+		c.WithValueIndex(l.hash)
+	}
+	if nil != l.keys {
+		c.WithKeyIndex()
+	}
+	if nil != l.bloom {
+		c.WithBloomFilter(len(l.bloom.counts)/10, l.bloom.hash)
+	}
+	if nil != l.agg {
+		c.WithMonoid(l.agg.zero, l.agg.lift, l.agg.combine)
+	}
+	for i, e := 0, l.Front(); nil != l.ttls && nil != e; i, e = i+1, e.Next() {
+		if d, ok := l.ttls[e]; ok {
+			c.expireAt(elems[i], d.key.(deadline).at)
+		}
+	}
+	return c
+}
+
+// Filter returns a new list, ordered like l, holding the entries of l for
+// which pred returns true, in O(N) time.  Values are copied shallowly.
+//
+func (l *T) Filter(pred func(key, value interface{}) bool) *T {
+	var front *Element
+	back, cnt := &front, 0
+	for e := l.Front(); nil != e; e = e.Next() {
+		if pred(e.key, e.Value) {
+			nu := &Element{e.key, e.Value, e.score, make([]link, 1), 0}
+			*back, back = nu, &nu.links[0].to
+			cnt++
+		}
+	}
+	return l.empty().fill(front, cnt)
+}
+
+// NewFromSorted returns a new ascending skiplist holding keys[i]:values[i]
+// for each i, in O(N) time.  The keys must already be sorted from least
+// to greatest; entries with equal keys keep their order, so the first is
+// treated as the youngest.  Rather than being chosen randomly, element
+// levels are assigned deterministically to produce a balanced list.
+//
+func NewFromSorted(keys, values []interface{}) *T {
+	if len(keys) != len(values) {
+		panic("skiplist: NewFromSorted requires one value per key")
+	}
+	l := New()
+	var front *Element
+	back := &front
+	for i, key := range keys {
+		e := &Element{key, values[i], l.score(key), make([]link, 1), 0}
+		if i > 0 && l.before(e, keys[i-1], l.score(keys[i-1])) {
+			panic("skiplist: NewFromSorted keys are not sorted")
+		}
+		*back = e
+		back = &e.links[0].to
+	}
+	return l.fill(front, len(keys))
+}
+
+// Return the first list element in O(1) time.
+//
+func (l *T) Front() *Element {
+	if len(l.links) == 0 {
+		return nil
+	}
+	return l.links[0].to
+}
+
+// Insert a {key,value} pair in the skiplist, optionally replacing the youngest previous entry.
+//
+func (l *T) insert(key interface{}, value interface{}, replace bool) *T {
+	l.guardKey(key)
+	l.link(&Element{key, value, l.score(key), nil, 0}, replace)
+	return l
+}
+
+// Function link links Element nu, whose key and score must already be set,
+// into the list, optionally replacing the youngest previous entry for its
+// key, which is returned.  The tower in nu.links is reused if it has enough capacity for the
+// new level count, so relinking a removed element need not allocate.
+// Kept is false if nu was evicted at once, as by WithMaxLen.
+//
+func (l *T) link(nu *Element, replace bool) (replaced *Element, kept bool) {
+	l.mutating()
+	l.guardValue(nu.Value)
+	l.grow()
+	key, s := nu.key, nu.score
+	prev, pos := l.prevs(key, s)
+	next := prev[0].link.to
+	if replace && nil != next && s == next.score &&
+		!l.less(key, next.key) && !l.less(next.key, key) {
+
+		replaced = l.remove(prev, next)
+	}
+	kept = l.linkAt(prev, pos, nu)
+	if nil != replaced && nil != l.onReplace {
+		l.onReplace(replaced, nu)
+	}
+	return replaced, kept
+}
+
+// Function linkN links Element nu into the list at position index, and
+// reports whether it was kept, as for link.  The caller must ensure this
+// keeps the list in order.
+//
+func (l *T) linkN(index int, nu *Element) bool {
+	l.mutating()
+	l.guardValue(nu.Value)
+	l.dropFinger()
+	l.grow()
+	return l.linkAt(l.prevsN(index), index, nu)
+}
+
+// Function linkAt links Element nu into the list at position pos, given the
+// precomputed predecessor list prev for that position, then evicts entries
+// beyond WithMaxLen, and reports whether nu was kept.  The list must
+// already have been grown to hold nu.
+//
+func (l *T) linkAt(prev []prev, pos int, nu *Element) bool {
+	levels := len(l.links)
+	if 0 < l.maxLevel && l.maxLevel < levels {
+		levels = l.maxLevel
+	}
+	nuLevels := l.randLevels(levels)
+	if nuLevels <= cap(nu.links) {
+		nu.links = nu.links[:nuLevels]
+	} else {
+		nu.links = make([]link, nuLevels)
+	}
+	for level := range prev {
+		if level < nuLevels {
+			if level == 0 {
+				// At the bottom level, simply link in the new Element of width 1
+				to := prev[level].link.to
+				prev[level].link.to = nu
+				nu.links[level].width = 1
+				nu.links[level].to = to
+				continue
+			}
+			// Link in the new element.
+			end := prev[level].pos + prev[level].link.width + 1
+			nu.links[level].to = prev[level].link.to
+			nu.links[level].width = end - pos
+			prev[level].link.to = nu
+			prev[level].link.width = pos - prev[level].pos
+			continue
+		}
+		// Higher levels just get a width adjustment.
+		prev[level].link.width += 1
+	}
+	if nil != l.agg {
+		l.reaggregate(prev, nu)
+	}
+	if 0 == nu.seq {
+		l.stamp(nu)
+	}
+	l.remember(nu, pos)
+	l.evict()
+
+	// The list held at most maxLen entries, so only the last can go.
+
+	return 0 == l.maxLen || pos < l.maxLen
+}
+
+// InsertElem is like Insert, but returns the new Element, so callers need
+// not search for it again.  It returns nil if the entry was evicted at
+// once, as by WithMaxLen.
+//
+func (l *T) InsertElem(key interface{}, value interface{}) *Element {
+	l.guardKey(key)
+	return l.linkElem(&Element{key, value, l.score(key), nil, 0})
+}
+
+// Function linkElem links Element nu into the list, as by link, and
+// returns it, or nil if it was evicted at once.
+//
+func (l *T) linkElem(nu *Element) *Element {
+	if _, kept := l.link(nu, false); !kept {
+		return nil
+	}
+	return nu
+}
+
+// InsertAfter inserts a {key,value} pair immediately after Element mark,
+// returning the new Element, if that keeps the list in order.  This
+// places the entry among others with equal keys.  If mark is not in the
+// list or the hint is wrong, the pair is inserted as by InsertElem.
+// Because the widths of all links spanning the new element change,
+// O(log(N)) time is required, for a single search, plus O(M) if mark is
+// one of M entries for its key.
+//
+func (l *T) InsertAfter(mark *Element, key interface{}, value interface{}) *Element {
+	return l.insertNear(mark, 1, key, value)
+}
+
+// InsertBefore is like InsertAfter, but inserts immediately before mark.
+//
+func (l *T) InsertBefore(mark *Element, key interface{}, value interface{}) *Element {
+	return l.insertNear(mark, 0, key, value)
+}
+
+// Function insertNear inserts a {key,value} pair at offset 0 or 1 from the
+// position of mark, if that keeps the list in order, and otherwise
+// inserts it normally.
+//
+func (l *T) insertNear(mark *Element, offset int, key interface{}, value interface{}) *Element {
+	l.mutating()
+	l.guardKey(key)
+	nu := &Element{key, value, l.score(key), nil, 0}
+	prevs := l.prevsElement(mark)
+	if nil == prevs {
+		return l.linkElem(nu)
+	}
+	pos := prevs[0].pos + 1 + offset
+
+	// Check the order against mark and its neighbour.  The element before
+	// mark need only be found if nu precedes the key of mark, and finding
+	// it spoils the predecessors found.
+
+	before, after := mark, mark.Next()
+	if 0 == offset {
+		before, after = nil, mark
+		if 0 < pos && l.before(nu, mark.key, mark.score) {
+			before, prevs = l.ElementN(pos-1), nil
+		}
+	}
+	if nil != before && l.before(nu, before.key, before.score) ||
+		nil != after && l.before(after, nu.key, nu.score) {
+
+		return l.linkElem(nu)
+	}
+
+	// Link nu in from the predecessors of mark, or after mark from its own
+	// tower, unless growing adds a level, which the search did not cover.
+
+	l.guardValue(value)
+	l.dropFinger()
+	levels := len(l.links)
+	l.grow()
+	if nil == prevs || len(l.links) != levels {
+		prevs = l.prevsN(pos)
+	} else if 1 == offset {
+		// Towers may keep stale levels, so use only those linked to mark.
+		for level := 0; level < len(prevs) && prevs[level].link.to == mark; level++ {
+			prevs[level] = prev{&mark.links[level], &mark.links, pos - 1}
+		}
+	}
+	if !l.linkAt(prevs, pos, nu) {
+		return nil
+	}
+	return nu
+}
+
+// InsertUnique inserts a {key,value} pair into the list, in O(log(N))
+// time, only if key is not already present, and reports whether it did.
+// Use it instead of Insert where duplicate keys would be a mistake.
+//
+func (l *T) InsertUnique(key interface{}, value interface{}) bool {
+	e, found := l.findOrInsert(key, func() interface{} { return value })
+	return !found && nil != e
+}
+
+// Function findOrInsert returns the youngest element for key, with found
+// true, or else links in and returns a new element for key with Value
+// value(), or nil if it was evicted at once, using a single search in
+// either case.
+//
+func (l *T) findOrInsert(key interface{}, value func() interface{}) (e *Element, found bool) {
+	l.mutating()
+	l.guardKey(key)
+	s := l.score(key)
+	prev, pos := l.prevs(key, s)
+	if e = prev[0].link.to; nil != e && s == e.score && !l.less(key, e.key) {
+		return e, true
+	}
+	e = &Element{key, value(), s, nil, 0}
+	l.guardValue(e.Value)
+
+	// Growing may add a level, which the search did not cover.
+
+	levels := len(l.links)
+	l.grow()
+	if len(l.links) != levels {
+		prev, pos = l.prevs(key, s)
+	}
+	if !l.linkAt(prev, pos, e) {
+		return nil, false
+	}
+	return e, false
+}
+
+// Errors returned by the Try methods, which check keys before use rather
+// than panicking on them.
+//
+var (
+	ErrUnsupportedKeyType = errors.New("skiplist: unsupported key type")
+	ErrKeyTypeMismatch    = errors.New("skiplist: key type does not match the list")
+	ErrNilKey             = errors.New("skiplist: nil key rejected")
+	ErrNaNKey             = errors.New("skiplist: NaN key rejected")
+)
+
+// TryInsert is like Insert, but returns an error rather than panicking if
+// the key cannot be ordered with the keys of the list.
+//
+func (l *T) TryInsert(key interface{}, value interface{}) error {
+	if err := l.checkKey(key); nil != err {
+		return err
+	}
+	l.insert(key, value, false)
+	return nil
+}
+
+// TrySet is like Set, but returns an error rather than panicking if the
+// key cannot be ordered with the keys of the list.
+//
+func (l *T) TrySet(key interface{}, value interface{}) error {
+	if err := l.checkKey(key); nil != err {
+		return err
+	}
+	l.insert(key, value, true)
+	return nil
+}
+
+// TryGet is like GetOk, but returns an error rather than panicking if the
+// key cannot be ordered with the keys of the list.
+//
+func (l *T) TryGet(key interface{}) (value interface{}, ok bool, err error) {
+	if err = l.checkKey(key); nil != err {
+		return nil, false, err
+	}
+	value, ok = l.GetOk(key)
+	return value, ok, nil
+}
+
+// StrictKeys makes the list check each key it is given to insert, and
+// panic with a clear message before modifying the list if the key cannot
+// be ordered with the keys of the list, and returns the list.  Without
+// the check, such a key panics deep within a search.  Checking makes
+// insertion somewhat slower; see also TryInsert.
+//
+func (l *T) StrictKeys() *T {
+	l.strict = true
+	return l
+}
+
+// A NilPolicy says how a list treats nil keys.
+//
+type NilPolicy int
+
+const (
+	// NilAllowed passes nil keys to the ordering functions like any
+	// other key.  Lists inferring their ordering from the key type then
+	// panic on nil keys.  This is the default.
+	NilAllowed NilPolicy = iota
+	// NilRejected makes inserting a nil key panic, and the Try methods
+	// return ErrNilKey, before the key reaches the ordering functions.
+	NilRejected
+	// NilFirst orders nil keys before all others, so sentinel entries
+	// may be kept at the front of the list.
+	NilFirst
+)
+
+// WithNilKeys sets the policy p for nil keys, and returns the list.  It
+// must be called before any key is inserted.
+//
+func (l *T) WithNilKeys(p NilPolicy) *T {
+	if 0 != l.cnt {
+		l.panicf("WithNilKeys called on a list with %d entries", l.cnt)
+	}
+	l.nils = p
+	if nil != l.fns {
+		l.lazy()
+	}
+	return l
+}
+
+// Function nilFirst returns less and score functions like less and score,
+// but ordering nil before all other keys.
+//
+func nilFirst(less func(a, b interface{}) bool, score func(a interface{}) float64) (func(a, b interface{}) bool, func(a interface{}) float64) {
+	return func(a, b interface{}) bool {
+			if nil == a || nil == b {
+				return nil == a && nil != b
+			}
+			return less(a, b)
+		}, func(a interface{}) float64 {
+			if nil == a {
+				return math.Inf(-1)
+			}
+			return score(a)
+		}
+}
+
+// A NaNPolicy says how a list treats floating-point NaN keys, which are
+// neither less than, greater than, nor equal to any key, and so cannot be
+// placed consistently in an ordered list.
+//
+type NaNPolicy int
+
+const (
+	// NaNAllowed passes NaN keys to the ordering functions like any
+	// other key, leaving their positions undefined and possibly
+	// misplacing other keys.  This is the default.
+	NaNAllowed NaNPolicy = iota
+	// NaNRejected makes inserting a NaN key panic, and the Try methods
+	// return ErrNaNKey.
+	NaNRejected
+	// NaNFirst orders NaN keys before all others, as equals.
+	NaNFirst
+	// NaNLast orders NaN keys after all others, as equals.
+	NaNLast
+)
+
+// WithNaNKeys sets the policy p for float32 and float64 NaN keys, and
+// returns the list.  It must be called before any key is inserted.
+//
+func (l *T) WithNaNKeys(p NaNPolicy) *T {
+	if 0 != l.cnt {
+		l.panicf("WithNaNKeys called on a list with %d entries", l.cnt)
+	}
+	l.nans = p
+	if nil != l.fns {
+		l.lazy()
+	}
+	return l
+}
+
+// Function isNaN returns true iff key is a float32 or float64 NaN.
+//
+func isNaN(key interface{}) bool {
+	switch k := key.(type) {
+	case float32:
+		return k != k
+	case float64:
+		return k != k
+	}
+	return false
+}
+
+// Function nanAt returns less and score functions like less and score,
+// but ordering NaN keys as equals before all others, or after all others
+// if last.
+//
+func nanAt(last bool, less func(a, b interface{}) bool, score func(a interface{}) float64) (func(a, b interface{}) bool, func(a interface{}) float64) {
+	extreme := math.Inf(-1)
+	if last {
+		extreme = math.Inf(1)
+	}
+	return func(a, b interface{}) bool {
+			aNaN, bNaN := isNaN(a), isNaN(b)
+			if aNaN || bNaN {
+				return aNaN != bNaN && aNaN != last
+			}
+			return less(a, b)
+		}, func(a interface{}) float64 {
+			if isNaN(a) {
+				return extreme
+			}
+			return score(a)
+		}
+}
+
+// Function guardValue panics if the list aggregates values and value
+// cannot be lifted, before the list is changed.
+//
+func (l *T) guardValue(value interface{}) {
+	if nil != l.agg {
+		l.agg.lift(value)
+	}
+}
+
+// Function guardKey panics if key fails checkKey and the list has StrictKeys,
+// or if key is nil or NaN and the list rejects such keys.
+//
+func (l *T) guardKey(key interface{}) {
+	if nil == key && NilRejected == l.nils {
+		l.panicf("nil key rejected")
+	}
+	if NaNRejected == l.nans && isNaN(key) {
+		l.panicf("NaN key rejected")
+	}
+	if !l.strict {
+		return
+	}
+	switch l.checkKey(key) {
+	case ErrUnsupportedKeyType:
+		l.panicf("key %v of type %T is not supported", key, key)
+	case ErrKeyTypeMismatch:
+		l.panicf("key %v of type %T cannot be ordered with keys of type %T", key, key, l.sample)
+	}
+}
+
+// Function checkKey returns ErrUnsupportedKeyType if the list would infer
+// its ordering from key and cannot, or ErrKeyTypeMismatch if key cannot be
+// compared with the key from which the ordering was inferred.  Nil and NaN
+// keys return ErrNilKey or ErrNaNKey if the list rejects them.
+//
+func (l *T) checkKey(key interface{}) (err error) {
+	if NaNRejected == l.nans && isNaN(key) {
+		return ErrNaNKey
+	}
+	if nil == key {
+		switch l.nils {
+		case NilRejected:
+			return ErrNilKey
+		case NilFirst:
+			return nil
+		}
+	}
+	if nil != l.fns && nil == l.sample {
+		defer func() {
+			if nil != recover() {
+				err = ErrUnsupportedKeyType
+			}
+		}()
+		l.fns(key)
+		return nil
+	}
+	if nil == l.sample {
+		return nil
+	}
+	defer func() {
+		if nil != recover() {
+			err = ErrKeyTypeMismatch
+		}
+	}()
+	l.score(key)
+	l.less(key, l.sample)
+	l.less(l.sample, key)
+	return nil
+}
+
+// Insert a {key,value} pair into the skip list in O(log(N)) time.
+//
+func (l *T) Insert(key interface{}, value interface{}) *T {
+	return l.insert(key, value, false)
+}
+
+// Get returns the value corresponding to key in the table in O(log(N)) time.
+// If there is no corresponding value, nil is returned.
+// If there are multiple corresponding values, the youngest is returned.
+//
+// If the list might contain an nil value, you may want to use GetOk instead.
+//
+// If the list has expirations, set by ExpireAfter, the expired entries
+// found are removed, so Get then modifies the list.
+//
+func (l *T) Get(key interface{}) (value interface{}) {
+	e := l.youngest(key)
+	if nil == e {
+		return nil
+	}
+	return e.Value
+}
+
+// GetOk returns the value corresponding to key in the table in O(log(N)) time.
+// The return value ok is true iff the key was present.
+// If there is no corresponding value, nil and false are returned.
+// If there are multiple corresponding values, the youngest is returned.
+//
+// If the list has expirations, set by ExpireAfter, the expired entries
+// found are removed, so GetOk then modifies the list.
+//
+func (l *T) GetOk(key interface{}) (value interface{}, ok bool) {
+	e := l.youngest(key)
+	if nil == e {
+		return nil, false
+	}
+	return e.Value, true
+}
+
+// GetAll returns all values coresponding to key in the list, starting with the youngest.
+// If no value corresponds, an empty slice is returned.
+// O(log(N)+V) time is required, where M is the number of values returned.
+//
+func (l *T) GetAll(key interface{}) (values []interface{}) {
+	s := l.score(key)
+	prevs, _ := l.prevs(key, s)
+	e := prevs[0].link.to
+	for nil != e && e.score == s && !l.less(key, e.key) {
+		values = append(values, e.Value)
+		e = e.links[0].to
+	}
+	return values
+}
+
+// GetAllElements returns all elements corresponding to key in the list, starting with the youngest.
+// If no element corresponds, an empty slice is returned.
+// O(log(N)+V) time is required, where V is the number of elements returned.
+//
+// Unlike GetAll, the elements may be passed to RemoveElement or have their Values updated.
+//
+func (l *T) GetAllElements(key interface{}) (elems []*Element) {
+	s := l.score(key)
+	prevs, _ := l.prevs(key, s)
+	for e := prevs[0].link.to; nil != e && e.score == s && !l.less(key, e.key); e = e.links[0].to {
+		elems = append(elems, e)
+	}
+	return elems
+}
+
+// Count returns the number of values corresponding to key in the list.
+// O(log(N)+V) time is required, where V is the number of values counted.
+//
+func (l *T) Count(key interface{}) (cnt int) {
+	s := l.score(key)
+	prevs, _ := l.prevs(key, s)
+	for e := prevs[0].link.to; nil != e && e.score == s && !l.less(key, e.key); e = e.links[0].to {
+		cnt++
+	}
+	return cnt
+}
+
+// Insert a {key,value} pair into the skip list in O(log(N)) time, replacing the youngest entry
+// for key, if any.
+//
+func (l *T) Set(key interface{}, value interface{}) *T {
+	return l.insert(key, value, true)
+}
+
+// SetElem is like Set, but returns the new Element and the Element it
+// replaced, or nil, so callers need not search for them again.  The new
+// Element is nil if it was evicted at once, as by WithMaxLen.
+//
+func (l *T) SetElem(key interface{}, value interface{}) (nu, replaced *Element) {
+	l.guardKey(key)
+	nu = &Element{key, value, l.score(key), nil, 0}
+	replaced, kept := l.link(nu, true)
+	if !kept {
+		return nil, replaced
+	}
+	return nu, replaced
+}
+
+// Upsert sets the value of the youngest entry for key to fn(old, true),
+// where old is its current value, or else inserts key with value
+// fn(nil, false), in O(log(N)) time, returning the entry's Element, or nil
+// if it was evicted at once, as by WithMaxLen.  Only a single search is
+// performed.  Function fn must not modify the list.
+//
+func (l *T) Upsert(key interface{}, fn func(old interface{}, exists bool) interface{}) *Element {
+	e, found := l.findOrInsert(key, func() interface{} { return fn(nil, false) })
+	if found {
+		l.setValue(e, fn(e.Value, true))
+	}
+	return e
+}
+
+// GetOrInsert returns the value of the youngest entry for key with loaded
+// true, if any.  Otherwise it inserts key with value and returns value with
+// loaded false.  Only a single O(log(N)) search is performed.
+//
+func (l *T) GetOrInsert(key, value interface{}) (actual interface{}, loaded bool) {
+	e, loaded := l.findOrInsert(key, func() interface{} { return value })
+	if nil == e {
+		return value, false
+	}
+	return e.Value, loaded
+}
+
+// IncrBy adds delta to the float64 value of the youngest entry for key,
+// inserting key with value delta if it is absent, and returns the new
+// value, using a single O(log(N)) search.  It panics if the existing value
+// is not a float64.
+//
+func (l *T) IncrBy(key interface{}, delta float64) float64 {
+	e, found := l.findOrInsert(key, func() interface{} { return delta })
+	if !found {
+		return delta
+	}
+	v, ok := e.Value.(float64)
+	if !ok {
+		l.panicf("IncrBy value for key %v is %T, not float64", key, e.Value)
+	}
+	v += delta
+	l.setValue(e, v)
+	return v
+}
+
+// CompareAndSwap sets the value of the youngest entry for key to nu if its
+// current value equals old, in O(log(N)) time, and reports whether it did.
+// The values are compared with ==, so old must be of a comparable type.
+//
+func (l *T) CompareAndSwap(key, old, nu interface{}) bool {
+	e := l.Element(key)
+	if nil == e || e.Value != old {
+		return false
+	}
+	l.setValue(e, nu)
+	return true
+}
+
+// Function remove removes Element elem from a list.  Parameter prevs must be
+// the precomputed predecessor list for the element.
+//
+func (l *T) remove(prev []prev, elem *Element) *Element {
+	l.mutating()
+	// At the bottom level, simply unlink the element.
+	prev[0].link.to = elem.links[0].to
+	// Unlink any higher linked levels.
+	level := 1
+	levels := len(l.links)
+	for ; level < levels && prev[level].link.to == elem; level++ {
+		prev[level].link.to = elem.links[level].to
+		prev[level].link.width += elem.links[level].width - 1
+	}
+	// Adjust widths at higher levels
+	for ; level < levels; level++ {
+		prev[level].link.width -= 1
+	}
+	if nil != l.agg {
+		l.reaggregate(prev, nil)
+	}
+	l.forget(elem, prev[0].pos+1)
+	l.shrink()
+	return elem
+}
+
+// Merge moves all entries of other, which must be ordered like l, into l,
+// leaving other empty, in O(N+M) time, where M is the length of other.
+// Duplicate keys are preserved, with entries from other treated as
+// younger than entries for the same key in l.  The levels of the merged
+// list are rebuilt to balance it.  The entries are reported to the hooks
+// of other as removed, before any is moved, and to those of l as
+// inserted, once the merged list is complete.
+//
+func (l *T) Merge(other *T) *T {
+	l.mutating()
+	other.mutating()
+	if other == l || 0 == other.cnt {
+		return l
+	}
+	other.discard()
+	var front *Element
+	back := &front
+	a, b := l.Front(), other.Front()
+	seq := l.seq
+	for nil != a && nil != b {
+		if l.before(a, b.key, b.score) {
+			*back, back, a = a, &a.links[0].to, a.links[0].to
+		} else {
+			l.stamp(b)
+			*back, back, b = b, &b.links[0].to, b.links[0].to
+		}
+	}
+	if nil != a {
+		*back = a
+	} else {
+		*back = b
+	}
+	for ; nil != b; b = b.links[0].to {
+		l.stamp(b)
+	}
+	l.rebuild(front, l.cnt+other.cnt)
+	other.reset()
+
+	// The moved elements are those stamped above.
+
+	for pos, e := 0, front; nil != e; pos, e = pos+1, e.links[0].to {
+		if e.seq > seq {
+			l.remember(e, pos)
+		}
+	}
+	l.evict()
+	return l
+}
+
+// Union returns a new list holding every entry of a, plus the entries of b
+// whose keys are not in a, in O(N+M) time, where N and M are the lengths of
+// a and b.  The lists must be ordered alike, and the result is ordered like
+// a.  Values are copied shallowly.
+//
+func Union(a, b *T) *T { return combine(a, b, true, true, true) }
+
+// Intersect returns a new list holding the entries of a whose keys are
+// also in b, in O(N+M) time.  The lists must be ordered alike.
+//
+func Intersect(a, b *T) *T { return combine(a, b, false, true, false) }
+
+// Difference returns a new list holding the entries of a whose keys are
+// not in b, in O(N+M) time.  The lists must be ordered alike.
+//
+func Difference(a, b *T) *T { return combine(a, b, true, false, false) }
+
+// Function combine walks lists a and b in step, copying into a new list the
+// entries of a whose keys are only in a, entries of a whose keys are in
+// both, and entries of b whose keys are only in b, as selected.
+//
+func combine(a, b *T, onlyA, both, onlyB bool) *T {
+	var front *Element
+	back, cnt := &front, 0
+	keep := func(e *Element) {
+		nu := &Element{e.key, e.Value, e.score, make([]link, 1), 0}
+		*back, back = nu, &nu.links[0].to
+		cnt++
+	}
+	x, y := a.Front(), b.Front()
+	for nil != x || nil != y {
+		switch {
+		case nil == y || nil != x && a.before(x, y.key, y.score):
+			if onlyA {
+				keep(x)
+			}
+			x = x.links[0].to
+		case nil == x || a.before(y, x.key, x.score):
+			if onlyB {
+				keep(y)
+			}
+			y = y.links[0].to
+		default:
+			k, s := x.key, x.score
+			for ; nil != x && !a.before(x, k, s) && x.score == s && !a.less(k, x.key); x = x.links[0].to {
+				if both {
+					keep(x)
+				}
+			}
+			for ; nil != y && !a.before(y, k, s) && y.score == s && !a.less(k, y.key); y = y.links[0].to {
+			}
+		}
+	}
+	return a.empty().fill(front, cnt)
+}
+
+// Remove the youngest Element associate with Key, if any, in O(log(N)) time.
+// Return the removed element or nil.
+//
+func (l *T) Remove(key interface{}) *Element {
+	s := l.score(key)
+	prevs, _ := l.prevs(key, s)
+	// Verify there is a matching entry to remove.
+	elem := prevs[0].link.to
+	if elem == nil || s != elem.score || s == elem.score && l.less(key, elem.key) {
+		return nil
+	}
+	return l.remove(prevs, elem)
+}
+
+// UpdateKey moves the youngest entry for oldKey so that it is keyed by newKey,
+// in O(log(N)) time, returning the moved Element or nil if oldKey is absent.
+// The Element and, when it fits, its tower allocation are reused, so
+// score-update-heavy workloads do not churn the allocator.  Any other
+// entries for newKey are kept, with the moved entry becoming the youngest.
+//
+func (l *T) UpdateKey(oldKey, newKey interface{}) *Element {
+	l.guardKey(newKey)
+	e := l.Remove(oldKey)
+	if nil == e {
+		return nil
+	}
+	e.key, e.score = newKey, l.score(newKey)
+	l.link(e, false)
+	return e
+}
+
+// LoadAndDelete removes the youngest entry for key, if any, returning its
+// value with loaded true, in a single O(log(N)) traversal.
+//
+func (l *T) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	if e := l.Remove(key); nil != e {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// CompareAndDelete removes the youngest entry for key if its value equals
+// old, in O(log(N)) time, and reports whether it did.  The values are
+// compared with ==, so old must be of a comparable type.
+//
+func (l *T) CompareAndDelete(key, old interface{}) bool {
+	s := l.score(key)
+	prevs, _ := l.prevs(key, s)
+	elem := prevs[0].link.to
+	if elem == nil || s != elem.score || l.less(key, elem.key) || elem.Value != old {
+		return false
+	}
+	l.remove(prevs, elem)
+	return true
+}
+
+// RemoveNext is like Remove, but also returns the element that followed the
+// removed element, or nil, so that deletion during iteration can continue
+// without another search.  If no element is removed, both results are nil.
+//
+func (l *T) RemoveNext(key interface{}) (removed, next *Element) {
+	if removed = l.Remove(key); nil != removed {
+		next = removed.Next()
+	}
+	return removed, next
+}
+
+// Remove the specified element from the table, in O(log(N)) time.
+// If the element is one of M multiple entries for the key, and additional O(M) time is required.
+// This is useful for removing a specific element in a multimap, or removing elements during iteration.
+// If the element is not in the list, nil is returned.
+//
+func (l *T) RemoveElement(e *Element) *Element {
+	prevs := l.prevsElement(e)
+	if nil == prevs {
+		return nil
+	}
+	return l.remove(prevs, e)
+}
+
+// ChangeKey re-keys Element e, which must be in the list, and moves it to
+// the sorted position for newKey, in O(log(N)) time plus O(M) time if e is
+// one of M entries for its old key.  The Element itself is reused, so
+// outstanding pointers to it and its Value remain valid.  Any other entries
+// for newKey are kept, with e becoming the youngest.  If e is not in the
+// list, nil is returned.
+//
+func (l *T) ChangeKey(e *Element, newKey interface{}) *Element {
+	l.mutating()
+	l.guardKey(newKey)
+	prevs := l.prevsElement(e)
+	if nil == prevs {
+		return nil
+	}
+	l.remove(prevs, e)
+	e.key, e.score = newKey, l.score(newKey)
+	l.link(e, false)
+	return e
+}
+
+// Function prevsElement returns the predecessor list for Element e, or nil
+// if e is not in the list.
+//
+func (l *T) prevsElement(e *Element) []prev {
+
+	// Find the first element in the multimap group.
+
+	k := e.key
+	s := l.score(k)
+	prevs, pos := l.prevs(k, s)
+
+	// Find the position of the matching entry within the multimap group.
+
+	for match := prevs[0].link.to; match != e; match = match.Next() {
+		if nil == match || s != match.score || l.less(k, match.key) {
+			return nil
+		}
+		pos++
+	}
+
+	// Adjust prevs to be relative to the element, not relative to the start of the group.
+
+	levels := len(prevs)
+	for level := 0; level < levels; level++ {
+		for p := &prevs[level]; p.pos+p.link.width < pos; {
+			p.pos += p.link.width
+			p.links = &p.link.to.links
+			p.link = &(*p.links)[level]
+		}
+	}
+	return prevs
+}
+
+// RemoveN removes any element at position pos in O(log(N)) time,
+// returning it or nil.
+//
+func (l *T) RemoveN(index int) *Element {
+	if index >= l.cnt {
+		return nil
+	}
+	prevs := l.prevsN(index)
+	elem := prevs[0].link.to
+	return l.remove(prevs, elem)
+}
+
+// RemoveNNext is like RemoveN, but also returns the element that followed
+// the removed element, which is now at position index, or nil.
+//
+func (l *T) RemoveNNext(index int) (removed, next *Element) {
+	if removed = l.RemoveN(index); nil != removed {
+		next = removed.Next()
+	}
+	return removed, next
+}
+
+// MoveN moves the element at position from to position to, in O(log(N))
+// time, reusing the Element, and returns the list.  This reorders entries
+// with equal keys, whose order is otherwise by age.  It panics if either
+// position is out of range, or if the move would put the list out of order.
+//
+func (l *T) MoveN(from, to int) *T {
+	if from < 0 || to < 0 || l.cnt <= from || l.cnt <= to {
+		l.panicf("MoveN positions %d and %d out of bounds for length %d", from, to, l.cnt)
+	}
+	if from == to {
+		return l
+	}
+
+	// Verify the element fits between its new neighbours.
+
+	e := l.ElementN(from)
+	before, after := to-1, to
+	if from < to {
+		before, after = to, to+1
+	}
+	if b := l.ElementN(before); 0 <= before && l.before(e, b.key, b.score) {
+		l.panicf("MoveN from %d to %d would put the list out of order", from, to)
+	}
+	if a := l.ElementN(after); nil != a && l.before(a, e.key, e.score) {
+		l.panicf("MoveN from %d to %d would put the list out of order", from, to)
+	}
+
+	// Relink the element.
+
+	l.remove(l.prevsN(from), e)
+	l.linkN(to, e)
+	return l
+}
+
+// SwapN exchanges the elements at positions i and j, which must have
+// equal keys, in O(log(N)) time, and returns the list.  It panics if
+// either position is out of range or the keys differ.
+//
+func (l *T) SwapN(i, j int) *T {
+	if i < 0 || j < 0 || l.cnt <= i || l.cnt <= j {
+		l.panicf("SwapN positions %d and %d out of bounds for length %d", i, j, l.cnt)
+	}
+	if i > j {
+		i, j = j, i
+	}
+	if a, b := l.ElementN(i), l.ElementN(j); l.before(a, b.key, b.score) {
+		l.panicf("SwapN keys at %d and %d differ", i, j)
+	}
+	if i == j {
+		return l
+	}
+	return l.MoveN(j, i).MoveN(i+1, j)
+}
+
+// A DeleteCursor walks the elements with keys in a range, and can remove
+// the current element without a fresh search.  The list must not be
+// modified except through the cursor while it is in use.
+//
+type DeleteCursor struct {
+	hi    interface{}
+	his   float64
+	l     *T
+	pos   int
+	prevs []prev
+}
+
+// DeleteCursor returns a cursor positioned at the first element with a key
+// in the interval [lo,hi), in O(log(N)) time.
+//
+func (l *T) DeleteCursor(lo, hi interface{}) *DeleteCursor {
+	prevs, pos := l.prevs(lo, l.score(lo))
+	return &DeleteCursor{hi, l.score(hi), l, pos, append([]prev{}, prevs...)}
+}
+
+// Element returns the current element, or nil if the cursor has passed the
+// end of its range.
+//
+func (c *DeleteCursor) Element() *Element {
+	e := c.prevs[0].link.to
+	if nil == e || !c.l.before(e, c.hi, c.his) {
+		return nil
+	}
+	return e
+}
+
+// Next advances the cursor past the current element in O(1) amortized time,
+// returning the new current element or nil.
+//
+func (c *DeleteCursor) Next() *Element {
+	if nil == c.Element() {
+		return nil
+	}
+	c.pos++
+	for level := range c.l.links {
+		for p := &c.prevs[level]; p.pos+p.link.width < c.pos; {
+			p.pos += p.link.width
+			p.links = &p.link.to.links
+			p.link = &(*p.links)[level]
+		}
+	}
+	return c.Element()
+}
+
+// DeleteCurrent removes the current element, if any, returning it, and
+// leaves the cursor at the element that followed it, in O(1) amortized time.
+//
+func (c *DeleteCursor) DeleteCurrent() *Element {
+	e := c.Element()
+	if nil == e {
+		return nil
+	}
+	return c.l.remove(c.prevs, e)
+}
+
+// A Segment is a run of elements cut from a list by SpliceOut, which
+// may be linked into a list with the same ordering by SpliceIn.
+//
+type Segment struct {
+	front, back *Element
+	cnt         int
+}
+
+// Front returns the first element of the segment, or nil.
+//
+func (seg *Segment) Front() *Element { return seg.front }
+
+// Len returns the number of elements in the segment.
+//
+func (seg *Segment) Len() int { return seg.cnt }
+
+// SpliceOut unlinks the elements at positions [lo,hi) from the list and
+// returns them as a Segment, in O(log(N)+K) time, where K is hi-lo.
+// It panics if the positions are out of range.
+//
+func (l *T) SpliceOut(lo, hi int) *Segment {
+	l.mutating()
+	l.dropFinger()
+	if lo < 0 || hi < lo || l.cnt < hi {
+		l.panicf("SpliceOut range [%d,%d) out of bounds for length %d", lo, hi, l.cnt)
+	}
+	seg := &Segment{cnt: hi - lo}
+	if 0 == seg.cnt {
+		return seg
+	}
+
+	// Find the last links before the run, and the last links within it.
+
+	before := append([]prev{}, l.prevsN(lo)...)
+	last := l.prevsN(hi)
+	seg.front = before[0].link.to
+	for e := seg.front; e != last[0].link.to; e = e.links[0].to {
+		l.forget(e, lo)
+		seg.back = e
+	}
+
+	// At each level, link over the run.
+
+	for level := range before {
+		b, e := &before[level], &last[level]
+		if e.pos < lo {
+			b.link.width -= seg.cnt
+			continue
+		}
+		b.link.to = e.link.to
+		b.link.width = e.pos + e.link.width - b.pos - seg.cnt
+	}
+	seg.back.links[0].to = nil
+
+	// Drop levels no longer needed.
+
+	l.cnt -= seg.cnt
+	levels := 0
+	for n := l.cnt; n > 0; n >>= 1 {
+		levels++
+	}
+	l.links = l.links[:levels]
+	l.prev = l.prev[:levels]
+	if nil != l.agg {
+		l.reaggregate(before, nil)
+	}
+	return seg
+}
+
+// Function fits reports whether the ordered segment seg fits between two
+// adjacent elements of the list, as SpliceIn requires.
+//
+func (l *T) fits(seg *Segment) bool {
+	back := seg.back
+	if _, pos := l.prevs(back.key, back.score); pos > 0 {
+		e := l.ElementN(pos - 1)
+		return !l.before(seg.front, e.key, e.score)
+	}
+	return true
+}
+
+// RemoveRange removes the elements with keys in the interval [lo,hi), in
+// O(log(N)+K) time, where K is the number removed, and returns K.  Neither
+// key need be present in the list.
+//
+func (l *T) RemoveRange(lo, hi interface{}) int {
+	_, first := l.prevs(lo, l.score(lo))
+	_, end := l.prevs(hi, l.score(hi))
+	if end <= first {
+		return 0
+	}
+	return l.SpliceOut(first, end).cnt
+}
+
+// Truncate removes all elements after the first n, in O(log(N)+K) time,
+// where K is the number removed, and returns the list.  It panics if n is
+// negative.
+//
+func (l *T) Truncate(n int) *T {
+	if n < 0 {
+		l.panicf("Truncate length %d is negative", n)
+	}
+	if n < l.cnt {
+		l.SpliceOut(n, l.cnt)
+	}
+	return l
+}
+
+// SpliceIn links the elements of seg into the list, in O(log(N)+K) time,
+// where K is the length of the segment, and leaves the segment empty.
+// The segment is placed at the earliest position that keeps the list in
+// order.  SpliceIn panics unless the segment is in order and fits
+// between two adjacent elements of the list.
+//
+func (l *T) SpliceIn(seg *Segment) {
+	l.mutating()
+	l.dropFinger()
+	if 0 == seg.cnt {
+		return
+	}
+	for e := seg.front; e != seg.back; e = e.links[0].to {
+		if next := e.links[0].to; l.before(next, e.key, e.score) {
+			l.panicf("SpliceIn segment is out of order")
+		}
+	}
+
+	// Add levels for the new elements.
+
+	cnt := l.cnt + seg.cnt
+	levels := 0
+	for n := cnt; n > 0; n >>= 1 {
+		levels++
+	}
+	for len(l.links) < levels {
+		l.links = append(l.links, link{width: l.cnt + 1})
+		l.prev = append(l.prev, prev{})
+	}
+
+	// Find the earliest position where the segment may go, and verify that it fits.
+
+	if !l.fits(seg) {
+		l.panicf("SpliceIn segment does not fit in the list")
+	}
+	back := seg.back
+	prevs, pos := l.prevs(back.key, back.score)
+
+	// Link the segment internally, noting its first and last element at each level.
+
+	first, last := make([]*Element, levels), make([]*Element, levels)
+	firstPos, lastPos := make([]int, levels), make([]int, levels)
+	i := 0
+	for e := seg.front; nil != e; e = e.links[0].to {
+		if len(e.links) > levels {
+			e.links = e.links[:levels]
+		}
+		for level := range e.links {
+			if nil == first[level] {
+				first[level], firstPos[level] = e, i
+			} else {
+				last[level].links[level].to = e
+				last[level].links[level].width = i - lastPos[level]
+			}
+			last[level], lastPos[level] = e, i
+		}
+		l.stamp(e)
+		l.remember(e, pos+i)
+		i++
+	}
+
+	// Link the segment into the list at each level.
+
+	for level, p := range prevs {
+		if nil == first[level] {
+			p.link.width += seg.cnt
+			continue
+		}
+		end := p.pos + p.link.width + seg.cnt
+		last[level].links[level].to = p.link.to
+		last[level].links[level].width = end - (pos + lastPos[level])
+		p.link.to = first[level]
+		p.link.width = pos + firstPos[level] - p.pos
+	}
+	if nil != l.agg {
+		for level, p := range prevs {
+			for e := first[level]; nil != e; e = e.links[level].to {
+				l.aggregate(&e.links, level)
+				if e == last[level] {
+					break
+				}
+			}
+			l.aggregate(p.links, level)
+		}
+	}
+	l.cnt = cnt
+	*seg = Segment{}
+	if nil != l.keys {
+		l.WithKeyIndex()
+	}
+	l.evict()
+}
+
+// Element returns the youngest list element for key and its position,
+// If there is no match, nil and -1 are returned.
+//
+// Consider using Get or GetAll instead if you only want Values.
+//
+func (l *T) ElementPos(key interface{}) (e *Element, pos int) {
+	s := l.score(key)
+	prev, pos := l.prevs(key, s)
+	elem := prev[0].link.to
+	if elem == nil || s < elem.score || s == elem.score && l.less(key, elem.key) {
+		return nil, -1
+	}
+	return elem, pos
+}
+
+// Element returns the youngest list element for key,
+// without modifying the list, in O(log(N)) time.
+// If there is no match, nil is returned.
+//
+// If the list has expirations, set by ExpireAfter, the expired entries
+// found are removed, so Element then modifies the list.
+//
+func (l *T) Element(key interface{}) (e *Element) {
+	return l.youngest(key)
+}
+
+// Function youngest returns the youngest element for key, or nil, using the
+// key index if there is one.  Expired entries for key are removed.
+//
+func (l *T) youngest(key interface{}) *Element {
+	if nil != l.bloom && !l.bloom.mayContain(key) {
+		return nil
+	}
+	var e *Element
+	if nil != l.keys {
+		e = l.keys[mapKey(key)]
+	}
+	if nil == e {
+		// Keys ordered as equal need not be equal values, as for NaN.
+		e, _ = l.ElementPos(key)
+	}
+	if nil != e && l.expired(e) {
+		l.RemoveElement(e)
+		return l.youngest(key)
+	}
+	return e
+}
+
+// WithKeyIndex makes the list maintain a map from each key to its youngest
+// entry, and returns the list.  Get, GetOk and Element then find present
+// keys in O(1) time, though absent keys still take O(log(N)) time.  Keys
+// must be comparable, or []byte, net.IP or TupleKey keys, which are
+// converted as by ToMap; the index panics on other slices.  Keys ordered
+// as equal but not equal values, such as *big.Int keys, are still found,
+// but in O(log(N)) time.  Building the index, as Merge and SpliceIn also
+// do, takes O(N) time.
+//
+func (l *T) WithKeyIndex() *T {
+	l.keys = map[interface{}]*Element{}
+	for e := l.Front(); nil != e; e = e.Next() {
+		if k := mapKey(e.key); nil == l.keys[k] {
+			l.keys[k] = e
+		}
+	}
+	return l
+}
+
+// WithBloomFilter makes the list maintain a counting Bloom filter of its
+// keys, as hashed by hash, and returns the list.  Get, GetOk and Element
+// then usually find absent keys absent in O(1) time.  The filter is sized
+// for n keys, using 10 bytes per key for about 1% false positives, which
+// grow if there are more keys.  Keys ordered as equal must hash equally.
+// Building the filter takes O(N) time.
+//
+func (l *T) WithBloomFilter(n int, hash func(key interface{}) uint64) *T {
+	if n < 1 {
+		n = 1
+	}
+	l.bloom = &bloom{make([]uint8, 10*n), hash}
+	for e := l.Front(); nil != e; e = e.Next() {
+		l.bloom.add(e.key, 1)
+	}
+	return l
+}
+
+// A bloom is a counting Bloom filter.  Each key adds to 7 counters,
+// chosen by double hashing, and saturated counters stick.
+//
+type bloom struct {
+	counts []uint8
+	hash   func(key interface{}) uint64
+}
+
+// Function add adds delta to the counters for key.
+//
+func (b *bloom) add(key interface{}, delta int) {
+	h := b.hash(key)
+	for i := uint64(0); i < 7; i++ {
+		c := &b.counts[(h+i*(h>>32|1))%uint64(len(b.counts))]
+		if 255 != *c {
+			*c = uint8(int(*c) + delta)
+		}
+	}
+}
+
+// Function mayContain returns false if key is surely absent.
+//
+func (b *bloom) mayContain(key interface{}) bool {
+	h := b.hash(key)
+	for i := uint64(0); i < 7; i++ {
+		if 0 == b.counts[(h+i*(h>>32|1))%uint64(len(b.counts))] {
+			return false
+		}
+	}
+	return true
+}
+
+// WithFinger makes the list remember where each search by key ends, and
+// start the next search there if its key is not less, and returns the
+// list.  Such a search requires O(log(D)) time rather than O(log(N)),
+// where D is the distance between the two positions, so runs of ascending
+// keys, as when replaying a log, are found and inserted faster.  Searches
+// then modify the list, so even concurrent reads require a lock.
+//
+func (l *T) WithFinger() *T {
+	l.finger = &finger{}
+	return l
+}
+
+// A finger records, for the key of the last search, the predecessor at each
+// level and its position.  Inserting at the key leaves them in place, but
+// any other modification of the list clears ok.
+//
+type finger struct {
+	key   interface{}
+	links []*[]link
+	ok    bool
+	pos   []int
+	score float64
+}
+
+// Function dropFinger marks the finger, if any, as invalid.
+//
+func (l *T) dropFinger() {
+	if nil != l.finger {
+		l.finger.ok = false
+	}
+}
+
+// SetWithTTL is like Set, but the entry expires after ttl, as by
+// ExpireAfter, and the list is returned.  No expiration is set if the
+// entry is evicted at once, as by WithMaxLen.
+//
+func (l *T) SetWithTTL(key, value interface{}, ttl time.Duration) *T {
+	if e, _ := l.SetElem(key, value); nil != e {
+		l.ExpireAfter(e, ttl)
+	}
+	return l
+}
+
+// ExpireAfter makes Element e, which must be in the list, expire after
+// ttl, as measured by the clock set by WithClock, replacing any earlier
+// expiration, in O(log(N)) time.  Expired entries are removed when Get,
+// GetOk or Element finds them, or by ExpireNow; until then they still
+// count in Len and are seen by iteration.  An expiration is dropped when
+// its entry is removed, including by ChangeKey or SpliceOut, and kept by
+// Clone.
+//
+// Once a list has expirations, Get, GetOk and Element modify it whenever
+// they find an expired entry, so even concurrent reads require a write
+// lock, and under CheckOwner lookups by other goroutines panic.
+//
+func (l *T) ExpireAfter(e *Element, ttl time.Duration) {
+	l.expireAt(e, l.now().Add(ttl).UnixNano())
+}
+
+// Function expireAt sets the deadline of Element e to at, in nanoseconds
+// since the epoch.
+//
+func (l *T) expireAt(e *Element, at int64) {
+	l.mutating()
+	if nil == l.expiry {
+		l.expiry, l.ttls = New(), map[*Element]*Element{}
+	}
+	if d, ok := l.ttls[e]; ok {
+		l.expiry.RemoveElement(d)
+	}
+	l.ttls[e] = l.expiry.InsertElem(deadline{at, e.seq}, e)
+}
+
+// ExpireNow removes every expired entry, in O(log(N)) time per entry
+// removed, and returns the number removed.
+//
+func (l *T) ExpireNow() int {
+	if nil == l.expiry {
+		return 0
+	}
+	now, n := l.now().UnixNano(), 0
+	for d := l.expiry.Front(); nil != d && d.key.(deadline).at <= now; d = l.expiry.Front() {
+		l.RemoveElement(d.Value.(*Element))
+		n++
+	}
+	return n
+}
+
+// WithClock makes the list read the time from now, rather than time.Now,
+// for expirations, and returns the list.  This suits tests, and lists
+// expiring by a logical clock.
+//
+func (l *T) WithClock(now func() time.Time) *T {
+	l.clock = now
+	return l
+}
+
+// Function now returns the time by the clock of the list.
+//
+func (l *T) now() time.Time {
+	if nil == l.clock {
+		return time.Now()
+	}
+	return l.clock()
+}
+
+// Function expired returns true iff Element e has expired.
+//
+func (l *T) expired(e *Element) bool {
+	if nil == l.ttls {
+		return false
+	}
+	d, ok := l.ttls[e]
+	return ok && d.key.(deadline).at <= l.now().UnixNano()
+}
+
+// A deadline orders expiring elements by time, then by insertion
+// sequence, implementing FastKey.
+//
+type deadline struct {
+	at  int64
+	seq uint64
+}
+
+func (a deadline) Less(b interface{}) bool {
+	d := b.(deadline)
+	return a.at < d.at || a.at == d.at && a.seq < d.seq
+}
+
+func (a deadline) Score() float64 { return float64(a.at) }
+
+// OnInsert makes the list call fn with each element linked into it, and
+// returns the list.  Together with OnRemove, this keeps external indexes
+// and counters in step with the list without wrapping its methods: every
+// entry added, including by Merge, SpliceIn, or re-keying with ChangeKey,
+// is reported by OnInsert, and every entry dropped, including by eviction,
+// expiry, SpliceOut, re-keying or Clear, by OnRemove.  Changing a value in
+// place, as by Upsert, is not reported.  The hooks are called while the
+// list is being changed, so they must not use the list.  Merge is the
+// exception: it reports the entries it moves as removed from the other
+// list before moving any, and as inserted into this one once it is
+// complete.  A nil fn removes the hook.
+//
+func (l *T) OnInsert(fn func(e *Element)) *T {
+	l.onInsert = fn
+	return l
+}
+
+// OnRemove makes the list call fn with each element unlinked from it, as
+// described for OnInsert, and returns the list.
+//
+func (l *T) OnRemove(fn func(e *Element)) *T {
+	l.onRemove = fn
+	return l
+}
+
+// OnReplace makes the list call fn when Set or SetElem replaces entry old
+// with entry nu for the same key, and returns the list.  The replacement
+// is also reported to OnRemove and OnInsert, before OnReplace.
+//
+func (l *T) OnReplace(fn func(old, nu *Element)) *T {
+	l.onReplace = fn
+	return l
+}
+
+// An EventKind tells what happened to an entry.
+//
+type EventKind int
+
+const (
+	Inserted EventKind = iota
+	Removed
+	Updated
+)
+
+// An Event reports a change to an entry of a list.  Value is the new
+// value of an inserted or updated entry, or the value of a removed one.
+//
+type Event struct {
+	Key   interface{}
+	Kind  EventKind
+	Value interface{}
+}
+
+// Size of the channel buffer of each watch.
+//
+const watchBuffer = 256
+
+// Watch returns a channel delivering an Event for each change to an entry
+// with a key in the interval [lo,hi), in the order made, until Unwatch.
+// Replacing an entry, as by Set, or re-keying it is delivered as a removal
+// and an insertion, and Clear delivers nothing.  Events are sent while
+// the list is being changed, so a watch that falls 256 events behind is
+// dropped and its channel closed, rather than blocking the change.  Each
+// change costs O(W) time for W watches with lo not greater than its key.
+//
+func (l *T) Watch(lo, hi interface{}) <-chan Event {
+	if nil == l.watches {
+		l.watches = l.empty()
+		l.watches.maxLen = 0
+	}
+	ch := make(chan Event, watchBuffer)
+	l.watches.Insert(lo, &watch{ch, hi})
+	return ch
+}
+
+// Unwatch stops the watch with channel ch, as returned by Watch, and
+// closes the channel.  It reports whether the watch was running.
+//
+func (l *T) Unwatch(ch <-chan Event) bool {
+	if nil == l.watches {
+		return false
+	}
+	for e := l.watches.Front(); nil != e; e = e.Next() {
+		if w := e.Value.(*watch); ch == w.ch {
+			l.watches.RemoveElement(e)
+			close(w.ch)
+			return true
+		}
+	}
+	return false
+}
+
+// A watch is the Value of each element of a list's watches, which are
+// keyed by the low end of their intervals.
+//
+type watch struct {
+	ch chan Event
+	hi interface{}
+}
+
+// Function notify sends an event of the given kind for element e to each
+// watch covering its key, dropping any watch that has fallen behind.
+//
+func (l *T) notify(kind EventKind, e *Element) {
+	var behind []*Element
+	for w := l.watches.Front(); nil != w && !l.before(e, w.key, w.score); w = w.Next() {
+		if wt := w.Value.(*watch); l.before(e, wt.hi, l.score(wt.hi)) {
+			select {
+			case wt.ch <- Event{e.key, kind, e.Value}:
+			default:
+				behind = append(behind, w)
+			}
+		}
+	}
+	for _, w := range behind {
+		l.watches.RemoveElement(w)
+		close(w.Value.(*watch).ch)
+	}
+}
+
+// WithOpLog makes the list keep a log of every change to its entries, for
+// replicating the list or auditing its changes, and returns the list.
+// Each insertion, removal, or change of value in place, as by Upsert, is
+// recorded as an Op with the next sequence number, counting from 1, and
+// the position of the entry when the change was made, so replaying the
+// ops in order by position reproduces the list.  Replacing an entry, as
+// by Set, or re-keying it is logged as a removal and an insertion, Clear
+// logs the removal of each entry from the front, and evictions and
+// expiries are logged like other removals.  The log grows until trimmed
+// by TrimOps.  Calling WithOpLog again starts a new, empty log.
+//
+func (l *T) WithOpLog() *T {
+	l.oplog = &oplog{first: 1}
+	return l
+}
+
+// An Op is a change to an entry of a list, as logged by WithOpLog.  Kind
+// is Inserted, Removed or Updated; Pos is the position of the entry; and
+// Value is the new value of an inserted or updated entry, or the value of
+// a removed one.
+//
+type Op struct {
+	Key   interface{}
+	Kind  EventKind
+	Pos   int
+	Seq   uint64
+	Value interface{}
+}
+
+// OpsSince returns the logged ops with sequence numbers greater than seq,
+// in order, in O(1) time plus the time to copy them, so a replica that
+// has applied the ops through seq can catch up.  OpsSince(0) returns the
+// whole log.  It panics if the list has no log, or if ops after seq have
+// been trimmed.
+//
+func (l *T) OpsSince(seq uint64) []Op {
+	if nil == l.oplog {
+		l.panicf("OpsSince called on a list without WithOpLog")
+	}
+	ops := l.oplog.ops
+	if seq+1 < l.oplog.first {
+		l.panicf("OpsSince(%d) needs ops trimmed before %d", seq, l.oplog.first)
+	}
+	if i := seq + 1 - l.oplog.first; i < uint64(len(ops)) {
+		return append([]Op{}, ops[i:]...)
+	}
+	return nil
+}
+
+// TrimOps discards the logged ops with sequence numbers up to and
+// including seq, once every replica has applied them, and returns the
+// list.  It panics if the list has no log.
+//
+func (l *T) TrimOps(seq uint64) *T {
+	if nil == l.oplog {
+		l.panicf("TrimOps called on a list without WithOpLog")
+	}
+	log := l.oplog
+	if seq < log.first {
+		return l
+	}
+	n := seq + 1 - log.first
+	if n > uint64(len(log.ops)) {
+		n = uint64(len(log.ops))
+	}
+	// Copy the rest, so the trimmed ops can be collected.
+	log.ops = append([]Op{}, log.ops[n:]...)
+	log.first += n
+	return l
+}
+
+// An oplog holds the ops logged for a list, from sequence number first.
+//
+type oplog struct {
+	first uint64
+	ops   []Op
+}
+
+// Function add logs a change of the given kind to element e at position
+// pos.
+//
+func (log *oplog) add(kind EventKind, e *Element, pos int) {
+	seq := log.first + uint64(len(log.ops))
+	log.ops = append(log.ops, Op{e.key, kind, pos, seq, e.Value})
+}
+
+// WithAggregate makes every link of the list carry the combination, by
+// combine, of the values of the elements it skips, and returns the list.
+// AggregateRange then combines the values of any range of keys in
+// O(log(N)) time, as for sums, minimums or maximums over a window of a
+// time series.  Function combine must be associative, with zero as its
+// identity, but need not be commutative; values are combined in list
+// order.  Inserting and removing entries, and setting values through the
+// methods of the list, keep the aggregates up to date in O(log(N)) time,
+// but values must not be changed by assigning Element.Value directly.
+// Building the aggregates takes O(N) time.  WithAggregate is WithMonoid
+// with each value lifted to itself.
+//
+func (l *T) WithAggregate(zero interface{}, combine func(a, b interface{}) interface{}) *T {
+	return l.WithMonoid(zero, func(v interface{}) interface{} { return v }, combine)
+}
+
+// WithMonoid is like WithAggregate, but each value is first lifted by lift
+// into the summary that combine combines, and returns the list.
+// ReduceRange then summarizes any range of keys in O(log(N)) time.  This
+// serves counts, sums, bounding boxes, hashes and the like with one
+// mechanism: to keep both a count and a sum, for example, lift a value v
+// to a pair {1, v} and combine pairs by adding them.  Function lift must
+// depend only on the value, and combine must be associative, with zero as
+// its identity.
+//
+func (l *T) WithMonoid(zero interface{}, lift func(value interface{}) interface{}, combine func(a, b interface{}) interface{}) *T {
+	l.agg = &aggregator{combine, lift, zero}
+	l.aggregateAll()
+	return l
+}
+
+// WithSums makes every link of the list carry the sum of the float64
+// values it skips, and returns the list.  SumRange then totals the values
+// of any range of keys in O(log(N)) time, as for metering over an
+// interval of timestamps.  Values must be float64, and changed only
+// through the methods of the list, such as IncrBy; inserting or setting a
+// value of another type panics, leaving the list unchanged.
+//
+func (l *T) WithSums() *T {
+	return l.WithMonoid(0.0, func(v interface{}) interface{} {
+		f, ok := v.(float64)
+		if !ok {
+			l.panicf("WithSums value %v is %T, not float64", v, v)
+		}
+		return f
+	}, func(a, b interface{}) interface{} { return a.(float64) + b.(float64) })
+}
+
+// An aggregator lifts values into the summaries carried by links, and
+// combines them.
+//
+type aggregator struct {
+	combine func(a, b interface{}) interface{}
+	lift    func(value interface{}) interface{}
+	zero    interface{}
+}
+
+// Function aggregate recomputes the aggregate of the link at level of the
+// tower links: the lifted value of the element it reaches at the bottom level,
+// and otherwise the combination of the links one level down that it
+// spans.
+//
+func (l *T) aggregate(links *[]link, level int) {
+	ln := &(*links)[level]
+	if 0 == level {
+		if nil == ln.to {
+			ln.agg = l.agg.zero
+		} else {
+			ln.agg = l.agg.lift(ln.to.Value)
+		}
+		return
+	}
+	agg := l.agg.zero
+	for width := 0; width < ln.width; {
+		below := &(*links)[level-1]
+		agg = l.agg.combine(agg, below.agg)
+		width += below.width
+		if nil == below.to {
+			break
+		}
+		links = &below.to.links
+	}
+	ln.agg = agg
+}
+
+// Function reaggregate recomputes the aggregates of the predecessor links
+// prev, and of the links of nu unless it is nil, from the bottom level up.
+// Any predecessors for levels the list has since dropped are ignored.
+//
+func (l *T) reaggregate(prev []prev, nu *Element) {
+	for level := 0; level < len(prev) && level < len(l.links); level++ {
+		if nil != nu && level < len(nu.links) {
+			l.aggregate(&nu.links, level)
+		}
+		l.aggregate(prev[level].links, level)
+	}
+}
+
+// Function aggregateAll recomputes the aggregates of every link, from the
+// bottom level up, in O(N) time.
+//
+func (l *T) aggregateAll() {
+	for level := range l.links {
+		for links := &l.links; ; links = &(*links)[level].to.links {
+			l.aggregate(links, level)
+			if nil == (*links)[level].to {
+				break
+			}
+		}
+	}
+}
+
+// Function mapKey returns key, or key as a string if it is a []byte, as
+// a netip.Addr if it is a net.IP, or as its MapKey if it is a TupleKey,
+// for use as a map key.
+//
+func mapKey(key interface{}) interface{} {
+	switch k := key.(type) {
+	case []byte:
+		return string(k)
+	case net.IP:
+		// Either form of an IPv4 address gives the same Addr.
+		a, _ := netip.AddrFromSlice(k.To16())
+		return a.Unmap()
+	case TupleKey:
+		return k.MapKey()
+	}
+	return key
+}
+
+// ElementPos returns the position of the youngest list element for key,
+// without modifying the list, in O(log(N)) time.
+// If there is no match, -1 is returned.
+//
+// Consider using Get or GetAll instead if you only want Values.
+//
+func (l *T) Pos(key interface{}) (pos int) {
+	_, pos = l.ElementPos(key)
+	return pos
+}
+
+// PosCeiling returns the position of the first element with a key not
+// less than key, or Len() if there is none, in O(log(N)) time.  The key
+// need not be present in the list, and the position is the number of
+// elements with lesser keys.
+//
+func (l *T) PosCeiling(key interface{}) int {
+	_, pos := l.prevs(key, l.score(key))
+	return pos
+}
+
+// PosFloor returns the position of the last element with a key not
+// greater than key, or -1 if there is none, in O(log(N)) time.  The key
+// need not be present in the list, and one more than the position is the
+// number of elements with keys not greater than key.
+//
+func (l *T) PosFloor(key interface{}) int {
+	return l.posAfter(key) - 1
+}
+
+// Quantile returns the element at quantile q of the list, by the
+// nearest-rank method, in O(log(N)) time: the first element such that a
+// fraction of at least q of the elements are at or before it.  Quantile
+// returns nil if the list is empty, and panics unless 0 <= q <= 1.
+//
+func (l *T) Quantile(q float64) *Element {
+	if !(0 <= q && q <= 1) {
+		l.panicf("Quantile %v out of range [0,1]", q)
+	}
+	if 0 == l.cnt {
+		return nil
+	}
+	return l.ElementN(max(int(math.Ceil(q*float64(l.cnt)))-1, 0))
+}
+
+// Median returns the median element of the list, or the lower of the two
+// middle elements if the length is even, in O(log(N)) time.  It returns
+// nil if the list is empty.
+//
+func (l *T) Median() *Element {
+	return l.Quantile(0.5)
+}
+
+// PercentRank returns the fraction of the elements of the list with keys
+// less than key, from 0 to 1, in O(log(N)) time.  The key need not be
+// present in the list.  PercentRank returns 0 if the list is empty.
+//
+func (l *T) PercentRank(key interface{}) float64 {
+	if 0 == l.cnt {
+		return 0
+	}
+	return float64(l.PosCeiling(key)) / float64(l.cnt)
+}
+
+// RandomElement returns an element of the list chosen uniformly at
+// random using rng, or nil if the list is empty, in O(log(N)) time.  If
+// rng is nil, the global source of math/rand/v2 is used, so sampling does
+// not disturb the levels the list chooses.
+//
+func (l *T) RandomElement(rng *rand.Rand) *Element {
+	if 0 == l.cnt {
+		return nil
+	}
+	return l.ElementN(intN(rng, l.cnt))
+}
+
+// Function intN returns a random int in [0,n) from rng, or from the global
+// source if rng is nil.
+//
+func intN(rng *rand.Rand, n int) int {
+	if nil == rng {
+		return rand.IntN(n)
+	}
+	return rng.IntN(n)
+}
+
+// Sample returns k distinct elements of the list chosen uniformly at
+// random using rng, in list order, in O(k*log(N)) time.  If the list has
+// no more than k elements, all are returned.  If rng is nil, the global
+// source of math/rand/v2 is used, as for RandomElement.
+//
+func (l *T) Sample(rng *rand.Rand, k int) []*Element {
+	k = min(k, l.cnt)
+	if k <= 0 {
+		return nil
+	}
+
+	// Choose k distinct positions by Floyd's algorithm, then visit them in order.
+
+	chosen := make(map[int]bool, k)
+	for j := l.cnt - k; j < l.cnt; j++ {
+		if i := intN(rng, j+1); chosen[i] {
+			chosen[j] = true
+		} else {
+			chosen[i] = true
+		}
+	}
+	pos := make([]int, 0, k)
+	for i := range chosen {
+		pos = append(pos, i)
+	}
+	sort.Ints(pos)
+	sample := make([]*Element, k)
+	for i, p := range pos {
+		sample[i] = l.ElementN(p)
+	}
+	return sample
+}
+
+// TopN returns the first k elements of the list, in order, in O(k) time.
+// If the list has no more than k elements, all are returned.  For the
+// greatest keys first, as for a leaderboard, use a list made by
+// NewDescending.
+//
+func (l *T) TopN(k int) []*Element {
+	return l.elementsFrom(l.Front(), min(k, l.cnt))
+}
+
+// BottomN returns the last k elements of the list, in order, in
+// O(log(N)+k) time.  If the list has no more than k elements, all are
+// returned.
+//
+func (l *T) BottomN(k int) []*Element {
+	k = min(k, l.cnt)
+	return l.elementsFrom(l.ElementN(l.cnt-max(k, 0)), k)
+}
+
+// Function elementsFrom returns the n elements starting with e.
+//
+func (l *T) elementsFrom(e *Element, n int) []*Element {
+	if n <= 0 {
+		return nil
+	}
+	elems := make([]*Element, n)
+	for i := range elems {
+		elems[i], e = e, e.links[0].to
+	}
+	return elems
+}
+
+// WithMaxLen bounds the list to n entries, and returns the list.  After
+// each insertion, the last entries are evicted until there are at most n,
+// so the list keeps the first n, such as the top n scores of a
+// leaderboard made by NewDescending.  An entry inserted after the first n
+// is evicted at once: methods returning the new Element, such as
+// InsertElem, SetElem, InsertAfter and Upsert, then return nil, and
+// InsertUnique reports false.  Re-keying an entry, as by ChangeKey, does
+// not change the length of the list, so evicts nothing.  A bound of 0
+// removes the bound.
+//
+func (l *T) WithMaxLen(n int) *T {
+	l.maxLen = n
+	l.evict()
+	return l
+}
+
+// Function evict removes elements from the back of the list until it
+// holds no more than the bound set by WithMaxLen, if any.
+//
+func (l *T) evict() {
+	for 0 < l.maxLen && l.maxLen < l.cnt {
+		prev := l.prevsN(l.cnt - 1)
+		l.remove(prev, prev[0].link.to)
+	}
+}
+
+// Function posAfter returns the position of the first element with a key
+// greater than key, or l.cnt if there is none.
+//
+func (l *T) posAfter(key interface{}) int {
+	s := l.score(key)
+	pos := -1
+	links := &l.links
+	for level := len(l.links) - 1; level >= 0; level-- {
+		for to := (*links)[level].to; nil != to && (to.score < s || to.score == s && !l.less(key, to.key)); to = (*links)[level].to {
+			pos += (*links)[level].width
+			links = &to.links
+		}
+	}
+	return pos + 1
+}
+
+// CountRange returns the number of elements with keys in the interval
+// [lo,hi), in O(log(N)) time.  Neither key need be present in the list.
+//
+func (l *T) CountRange(lo, hi interface{}) int {
+	_, first := l.prevs(lo, l.score(lo))
+	_, end := l.prevs(hi, l.score(hi))
+	if end < first {
+		return 0
+	}
+	return end - first
+}
+
+// HasRange returns true iff the list holds any key in the interval [lo,hi),
+// in O(log(N)) time.  Only a single search, for lo, is needed, making this
+// cheaper than CountRange as a guard before range operations.
+//
+func (l *T) HasRange(lo, hi interface{}) bool {
+	prevs, _ := l.prevs(lo, l.score(lo))
+	e := prevs[0].link.to
+	return nil != e && l.before(e, hi, l.score(hi))
+}
+
+// AggregateRange returns the combination, by the function passed to
+// WithAggregate, of the values of the entries with keys in the interval
+// [lo,hi), in list order, in O(log(N)) time.  Neither key need be present
+// in the list.  If no keys are in the interval, the zero passed to
+// WithAggregate is returned.  AggregateRange panics if the list has no
+// aggregates.
+//
+func (l *T) AggregateRange(lo, hi interface{}) interface{} {
+	return l.reduceRange("AggregateRange", lo, hi)
+}
+
+// ReduceRange returns the combination, by the functions passed to
+// WithMonoid, of the lifted values of the entries with keys in the
+// interval [lo,hi), in list order, in O(log(N)) time.  Neither key need be
+// present in the list.  If no keys are in the interval, the zero passed
+// to WithMonoid is returned.  ReduceRange panics if the list has no
+// aggregates.
+//
+func (l *T) ReduceRange(lo, hi interface{}) interface{} {
+	return l.reduceRange("ReduceRange", lo, hi)
+}
+
+// SumRange returns the sum of the values of the entries with keys in the
+// interval [lo,hi), in O(log(N)) time.  Neither key need be present in the
+// list.  SumRange panics unless the list has sums, as from WithSums.
+//
+func (l *T) SumRange(lo, hi interface{}) float64 {
+	sum, ok := l.reduceRange("SumRange", lo, hi).(float64)
+	if !ok {
+		l.panicf("SumRange called on a list without WithSums")
+	}
+	return sum
+}
+
+// Function reduceRange implements AggregateRange, ReduceRange and SumRange
+// for the named method.
+//
+func (l *T) reduceRange(method string, lo, hi interface{}) interface{} {
+	if nil == l.agg {
+		l.panicf("%s called on a list without aggregates", method)
+	}
+	_, first := l.prevs(lo, l.score(lo))
+	_, end := l.prevs(hi, l.score(hi))
+	if end <= first {
+		return l.agg.zero
+	}
+	return l.aggregateFrom(&l.links, len(l.links)-1, -1, first, end)
+}
+
+// SearchRange returns the entries with keys in the interval [lo,hi), in
+// order, whose lifted values, as by WithMonoid, satisfy pred.  Links whose
+// summaries fail pred are skipped without visiting the entries under
+// them, so pred must fail for the combination of any summaries for which
+// it fails individually, as "the maximum exceeds x" does for a maximum.
+// This takes O((K+1)*log(N)) time, where K is the number returned, if
+// pred is exact, as for finding the intervals containing a point when the
+// summary is the maximum interval end.  SearchRange panics if the list has
+// no aggregates.
+//
+func (l *T) SearchRange(lo, hi interface{}, pred func(summary interface{}) bool) []*Element {
+	if nil == l.agg {
+		l.panicf("SearchRange called on a list without aggregates")
+	}
+	_, first := l.prevs(lo, l.score(lo))
+	_, end := l.prevs(hi, l.score(hi))
+	if end <= first {
+		return nil
+	}
+	return l.searchFrom(&l.links, len(l.links)-1, -1, first, end, pred, nil)
+}
+
+// Function searchFrom appends to found the elements at positions [lo,hi),
+// which must follow pos, reached at level from the tower links of the
+// element at position pos, whose summaries satisfy pred, as for
+// aggregateFrom.
+//
+func (l *T) searchFrom(links *[]link, level, pos, lo, hi int, pred func(interface{}) bool, found []*Element) []*Element {
+	for {
+		ln := &(*links)[level]
+		next := pos + ln.width
+		if lo <= next && pred(ln.agg) {
+			if 0 == level {
+				found = append(found, ln.to)
+			} else {
+				found = l.searchFrom(links, level-1, pos, max(lo, pos+1), min(hi, next+1), pred, found)
+			}
+		}
+		if next >= hi-1 {
+			return found
+		}
+		pos, links = next, &ln.to.links
+	}
+}
+
+// Function aggregateFrom returns the combination of the values at
+// positions [lo,hi), which must follow pos, reached at level from the
+// tower links of the element at position pos.  Links that lie within the
+// interval contribute their aggregates, and links that straddle its ends
+// are split at the level below, so O(log(N)) links are visited.  Towers
+// are never searched upward, since an element may keep stale links above
+// the levels at which it is linked.
+//
+func (l *T) aggregateFrom(links *[]link, level, pos, lo, hi int) interface{} {
+	agg := l.agg.zero
+	for {
+		ln := &(*links)[level]
+		next := pos + ln.width
+		if lo <= pos+1 && next < hi {
+			agg = l.agg.combine(agg, ln.agg)
+		} else if lo <= next {
+			agg = l.agg.combine(agg, l.aggregateFrom(links, level-1, pos, max(lo, pos+1), min(hi, next+1)))
+		}
+		if next >= hi-1 {
+			return agg
+		}
+		pos, links = next, &ln.to.links
+	}
+}
+
+// CooperativeForEach calls fn for each element in the list, in order,
+// calling runtime.Gosched after every yieldEvery elements so that a long
+// scan does not starve other goroutines.  If yieldEvery is not positive,
+// the scan never yields.  Fn must not modify the list.
+//
+func (l *T) CooperativeForEach(fn func(e *Element), yieldEvery int) {
+	n := 0
+	for e := l.Front(); nil != e; e = e.Next() {
+		fn(e)
+		if n++; n == yieldEvery {
+			runtime.Gosched()
+			n = 0
+		}
+	}
+}
+
+// Keys returns the keys of all elements in the list, in order, in O(N) time.
+//
+func (l *T) Keys() []interface{} {
+	keys := make([]interface{}, 0, l.cnt)
+	for e := l.Front(); nil != e; e = e.Next() {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// Values returns the values of all elements in the list, in order, in O(N) time.
+//
+func (l *T) Values() []interface{} {
+	values := make([]interface{}, 0, l.cnt)
+	for e := l.Front(); nil != e; e = e.Next() {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+// TrackBelow arranges for CountBelow(score) to require O(1) time by maintaining
+// a running count of the elements scoring below score.  Registering the
+// threshold requires O(log(N)) time, and each tracked threshold adds O(1) time
+// to every later insertion and removal.
+//
+// Scores are those used to order the list: FastKey.Score() for FastKeys, and
+// the key itself for numeric keys, in ascending lists.  Descending lists
+// negate them, so there CountBelow(-s) counts the elements scoring above s.
+//
+func (l *T) TrackBelow(score float64) *T {
+	l.tracked = append(l.tracked, threshold{score, l.CountBelow(score)})
+	return l
+}
+
+// CountBelow returns the number of elements scoring below score,
+// in O(1) time if the threshold is tracked, or O(log(N)) time otherwise.
+//
+func (l *T) CountBelow(score float64) int {
+	for _, t := range l.tracked {
+		if t.score == score {
+			return t.below
+		}
+	}
+	pos := -1
+	links := &l.links
+	for level := len(l.links) - 1; level >= 0; level-- {
+		for (*links)[level].to != nil && (*links)[level].to.score < score {
+			pos += (*links)[level].width
+			links = &(*links)[level].to.links
+		}
+	}
+	return pos + 1
+}
+
+// RangeByScore returns the elements scoring in the interval [min,max], in
+// order, skipping the first offset of them and returning at most limit, or
+// all if limit is negative, like Redis's ZRANGEBYSCORE.  Scores are those
+// of the keys, as for TrackBelow in ascending lists, so a descending list
+// returns its elements from max down to min, like ZREVRANGEBYSCORE.  It
+// panics on lists made WithoutScores, or by NewFunc or NewByKeyFunc, whose
+// keys may all score alike.  O(log(N)+V) time is required, where V is
+// the number of elements returned.
+//
+func (l *T) RangeByScore(min, max float64, offset, limit int) []*Element {
+	if offset < 0 {
+		l.panicf("RangeByScore offset %d is negative", offset)
+	}
+	if l.unscored {
+		l.panicf("RangeByScore called on a list without scores")
+	}
+	if l.reversed {
+		min, max = -max, -min
+	}
+	var elems []*Element
+	pos := l.CountBelow(min) + offset
+	if pos >= l.cnt {
+		return elems
+	}
+	for e := l.ElementN(pos); nil != e && e.score <= max && len(elems) != limit; e = e.Next() {
+		elems = append(elems, e)
+	}
+	return elems
+}
+
+// RangeByLex returns the elements with keys between min and max, in
+// order, like Redis's ZRANGEBYLEX.  Each bound is included if its incl
+// argument is true, and a nil bound is unbounded.  Keys beginning with p
+// lie between p, included, and PrefixEnd(p), excluded.  RangeByLex panics
+// unless the list orders string or []byte keys bytewise from least to
+// greatest, as New does.  O(log(N)+V) time is required, where V is the
+// number of elements returned.
+//
+func (l *T) RangeByLex(min, max []byte, minIncl, maxIncl bool) []*Element {
+	if l.reversed {
+		l.panicf("RangeByLex requires an ascending list")
+	}
+	if !l.bytewise {
+		l.panicf("RangeByLex requires a list ordering keys bytewise")
+	}
+	if 0 == l.cnt {
+		return nil
+	}
+	key := func(b []byte) interface{} { return b }
+	if _, ok := l.Front().key.(string); ok {
+		key = func(b []byte) interface{} { return string(b) }
+	}
+	lo, hi := 0, l.cnt
+	if nil != min {
+		if minIncl {
+			lo = l.PosCeiling(key(min))
+		} else {
+			lo = l.posAfter(key(min))
+		}
+	}
+	if nil != max {
+		if maxIncl {
+			hi = l.posAfter(key(max))
+		} else {
+			hi = l.PosCeiling(key(max))
+		}
+	}
+	var elems []*Element
+	if lo < hi {
+		for e := l.ElementN(lo); len(elems) < hi-lo; e = e.Next() {
+			elems = append(elems, e)
+		}
+	}
+	return elems
+}
+
+// ParseLexBound parses a bound in the syntax of Redis's ZRANGEBYLEX for
+// RangeByLex: "[" or "(" followed by the bound, included or excluded, or
+// "-" or "+" for no bound.
+//
+func ParseLexBound(s string) (bound []byte, incl bool, err error) {
+	switch {
+	case "-" == s || "+" == s:
+		return nil, false, nil
+	case strings.HasPrefix(s, "["):
+		return []byte(s[1:]), true, nil
+	case strings.HasPrefix(s, "("):
+		return []byte(s[1:]), false, nil
+	}
+	return nil, false, fmt.Errorf("skiplist: invalid lex bound %q", s)
+}
+
+// PrefixEnd returns the least key greater than every key beginning with
+// prefix, or nil if there is none, for use as an excluded RangeByLex bound.
+//
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// ToMap returns a map from each key in the list to its youngest value,
+// in O(N) time.  Since slices cannot be map keys, []byte keys are
+// converted to strings, net.IP keys to netip.Addr, with IPv4 addresses
+// unmapped, and TupleKey keys to their MapKey.  It panics on keys of other
+// types that cannot be map keys, such as other slices.
+//
+func (l *T) ToMap() map[interface{}]interface{} {
+	m := make(map[interface{}]interface{}, l.cnt)
+	for e := l.Front(); nil != e; e = e.Next() {
+		k := mapKey(e.key)
+		if _, dup := m[k]; !dup {
+			m[k] = e.Value
+		}
+	}
+	return m
+}
+
+// All returns an iterator over the key/value pairs of the list, in order.
+// The iterator has the form of an iter.Seq2, so it may be ranged over and
+// composed with iterator combinators without materializing a slice.
+// Each step requires O(1) time.
+//
+func (l *T) All() func(yield func(key, value interface{}) bool) {
+	return func(yield func(key, value interface{}) bool) {
+		for e := l.Front(); nil != e && yield(e.key, e.Value); e = e.Next() {
+		}
+	}
+}
+
+// AllKeys returns an iterator, in the form of an iter.Seq, over the keys of the list, in order.
+//
+func (l *T) AllKeys() func(yield func(key interface{}) bool) {
+	return func(yield func(key interface{}) bool) {
+		for e := l.Front(); nil != e && yield(e.key); e = e.Next() {
+		}
+	}
+}
+
+// AllValues returns an iterator, in the form of an iter.Seq, over the values of the list, in order.
+//
+func (l *T) AllValues() func(yield func(value interface{}) bool) {
+	return func(yield func(value interface{}) bool) {
+		for e := l.Front(); nil != e && yield(e.Value); e = e.Next() {
+		}
+	}
+}
+
+// InsertedBefore returns true iff element a was inserted into the list
+// before element b, in O(1) time.  Each element is numbered as it is
+// inserted, whether singly, in bulk, or by Merge or SpliceIn, and keeps
+// its number when moved or given a new key.  Clones share the numbers of
+// the original.
+//
+func (l *T) InsertedBefore(a, b *Element) bool { return a.seq < b.seq }
+
+// BySequence returns an iterator, in the form of an iter.Seq2, over the
+// key/value pairs of the list in the order they were inserted, as by
+// InsertedBefore.  Starting the iteration requires O(N*log(N)) time.
+//
+func (l *T) BySequence() func(yield func(key, value interface{}) bool) {
+	return func(yield func(key, value interface{}) bool) {
+		elems := make([]*Element, 0, l.cnt)
+		for e := l.Front(); nil != e; e = e.Next() {
+			elems = append(elems, e)
+		}
+		sort.Slice(elems, func(i, j int) bool { return elems[i].seq < elems[j].seq })
+		for _, e := range elems {
+			if !yield(e.key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Range returns an iterator, in the form of an iter.Seq2, over the key/value
+// pairs with keys in the interval [lo,hi), in order.  Starting the iteration
+// requires O(log(N)) time, and each step O(1) time.
+//
+func (l *T) Range(lo, hi interface{}) func(yield func(key, value interface{}) bool) {
+	return func(yield func(key, value interface{}) bool) {
+		prevs, _ := l.prevs(lo, l.score(lo))
+		s := l.score(hi)
+		for e := prevs[0].link.to; nil != e && (e.score < s || e.score == s && l.less(e.key, hi)); e = e.Next() {
+			if !yield(e.key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Walk calls fn for each element of the list, in order, stopping early if
+// fn returns false.  Each step requires O(1) time.  Function fn must not
+// modify the list.
+//
+func (l *T) Walk(fn func(e *Element) bool) {
+	for e := l.Front(); nil != e && fn(e); e = e.Next() {
+	}
+}
+
+// WalkRange is like Walk, but visits only the elements with keys in the
+// interval [lo,hi).  Starting the walk requires O(log(N)) time.
+//
+func (l *T) WalkRange(lo, hi interface{}, fn func(e *Element) bool) {
+	prevs, _ := l.prevs(lo, l.score(lo))
+	s := l.score(hi)
+	for e := prevs[0].link.to; nil != e && l.before(e, hi, s) && fn(e); e = e.Next() {
+	}
+}
+
+// A View is a read-only window onto a run of consecutive elements of a
+// list.  The run is recomputed from the list each time the view is used,
+// so no elements are copied.
+//
+type View struct {
+	bounds  func() (lo, hi int)
+	l       *T
+	reverse bool
+}
+
+// HeadN returns a view of the first n elements of the list, or of the whole
+// list if it is shorter.  It panics if n is negative.
+//
+func (l *T) HeadN(n int) *View {
+	if n < 0 {
+		l.panicf("HeadN length %d is negative", n)
+	}
+	return &View{func() (int, int) {
+		if n < l.cnt {
+			return 0, n
+		}
+		return 0, l.cnt
+	}, l, false}
+}
+
+// TailN returns a view of the last n elements of the list, or of the whole
+// list if it is shorter.  It panics if n is negative.
+//
+func (l *T) TailN(n int) *View {
+	if n < 0 {
+		l.panicf("TailN length %d is negative", n)
+	}
+	return &View{func() (int, int) {
+		if n < l.cnt {
+			return l.cnt - n, l.cnt
+		}
+		return 0, l.cnt
+	}, l, false}
+}
+
+// Sub returns a view of the elements with keys in the interval [lo,hi).
+// Like every view, it reflects later changes to the list, so it may be
+// handed to a consumer as a bounded window without copying.  Using the
+// view requires an extra O(log(N)) time to locate its bounds.
+//
+func (l *T) Sub(lo, hi interface{}) *View {
+	return &View{func() (int, int) {
+		_, first := l.prevs(lo, l.score(lo))
+		_, end := l.prevs(hi, l.score(hi))
+		if end < first {
+			return first, first
+		}
+		return first, end
+	}, l, false}
+}
+
+// Descending returns a view of the whole list in reverse order, without
+// re-sorting, so one list can serve both sort directions.  Position i of
+// the view is position Len()-1-i of the list.
+//
+func (l *T) Descending() *View {
+	return &View{func() (int, int) { return 0, l.cnt }, l, true}
+}
+
+// Descending returns a view of the same elements as v, in reverse order.
+//
+func (v *View) Descending() *View {
+	return &View{v.bounds, v.l, !v.reverse}
+}
+
+// Len returns the number of elements in the view.
+//
+func (v *View) Len() int {
+	lo, hi := v.bounds()
+	return hi - lo
+}
+
+// Front returns the first element of the view, or nil, in O(log(N)) time.
+//
+func (v *View) Front() *Element { return v.ElementN(0) }
+
+// ElementN returns the element at position index of the view, or nil, in
+// O(log(N)) time.
+//
+func (v *View) ElementN(index int) *Element {
+	lo, hi := v.bounds()
+	if index < 0 || hi-lo <= index {
+		return nil
+	}
+	if v.reverse {
+		return v.l.ElementN(hi - 1 - index)
+	}
+	return v.l.ElementN(lo + index)
+}
+
+// Element returns the youngest element for key, if it is in the view, or
+// nil, in O(log(N)) time.
+//
+func (v *View) Element(key interface{}) *Element {
+	e, pos := v.l.ElementPos(key)
+	if lo, hi := v.bounds(); nil == e || pos < lo || hi <= pos {
+		return nil
+	}
+	return e
+}
+
+// GetOk returns the youngest value for key, with ok true, if it is in the
+// view, in O(log(N)) time.  Otherwise nil and false are returned.
+//
+func (v *View) GetOk(key interface{}) (value interface{}, ok bool) {
+	if e := v.Element(key); nil != e {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// All returns an iterator, in the form of an iter.Seq2, over the key/value
+// pairs of the view, in order, stopping at the end of the view.  Starting
+// the iteration requires O(log(N)) time, and each step O(1) time, except
+// that each step of a descending view requires O(log(N)) time, since
+// the list is linked in only one direction.
+//
+func (v *View) All() func(yield func(key, value interface{}) bool) {
+	return func(yield func(key, value interface{}) bool) {
+		lo, hi := v.bounds()
+		if lo == hi {
+			return
+		}
+		if v.reverse {
+			for pos := hi - 1; lo <= pos; pos-- {
+				if e := v.l.ElementN(pos); !yield(e.key, e.Value) {
+					return
+				}
+			}
+			return
+		}
+		e := v.l.ElementN(lo)
+		for n := hi - lo; 0 < n && yield(e.key, e.Value); n-- {
+			e = e.Next()
+		}
+	}
+}
+
+// Clear removes all elements from the list in O(1) time, keeping its
+// ordering, so a long-lived list can be reused without inferring the key
+// type again.
+//
+func (l *T) Clear() *T {
+	l.discard()
+	l.reset()
+	return l
+}
+
+// Function discard reports every element of the list as removed from the
+// front to the op log and OnRemove hook, if any, before the list is reset.
+//
+func (l *T) discard() {
+	l.mutating()
+	for e := l.Front(); nil != l.oplog && nil != e; e = e.Next() {
+		l.oplog.add(Removed, e, 0)
+	}
+	for e := l.Front(); nil != l.onRemove && nil != e; e = e.Next() {
+		l.onRemove(e)
+	}
+}
+
+// Function reset empties the list and its auxiliary state in O(1) time,
+// without reporting the elements dropped.
+//
+func (l *T) reset() {
+	l.resize(0, nil)
+	for i := range l.tracked {
+		l.tracked[i].below = 0
+	}
+	if nil != l.hash {
+		l.values = map[uint64][]*Element{}
+	}
+	if nil != l.keys {
+		l.keys = map[interface{}]*Element{}
+	}
+	if nil != l.bloom {
+		clear(l.bloom.counts)
+	}
+	l.expiry, l.ttls = nil, nil
+}
+
+// Len returns the number of elements in the skiplist.
+//
+func (l *T) Len() int {
+	return l.cnt
+}
+
+// ElementN returns the Element at position pos in the skiplist, in O(log(index)) time.
+// If no such entry exists, nil is returned.
+//
+func (l *T) ElementN(index int) *Element {
+	if index >= l.cnt {
+		return nil
+	}
+	prev := l.prevsN(index)
+	return prev[0].link.to
+}
+
+// RenamePrefix replaces the prefix old with nu in every string or []byte
+// key that begins with old, preserving the relative order of the renamed
+// entries, and returns the number of entries renamed.  The run of renamed
+// entries is spliced out, rekeyed in place, and spliced back in, in
+// O(log(N)+K) time, where K is the number of entries renamed, so pointers
+// to their Elements remain valid.  If keys already in the list would fall
+// among the renamed ones, each renamed entry is instead relinked alone,
+// in O(K*log(N)) time.  As for SpliceOut and SpliceIn, the entries are
+// reported as removed and inserted again, and lose any expirations.
+//
+// RenamePrefix panics unless the list orders keys bytewise, as New and
+// NewDescending do.
+//
+func (l *T) RenamePrefix(old, nu []byte) int {
+	l.mutating()
+	if !l.bytewise {
+		l.panicf("RenamePrefix requires a list ordering keys bytewise")
+	}
+	if l.cnt == 0 {
+		return 0
+	}
+
+	// Convert between []byte prefixes and the key type of the list.
+
+	_, str := l.links[0].to.key.(string)
+	key := func(b []byte) interface{} {
+		if str {
+			return string(b)
+		}
+		return b
+	}
+
+	// Find the run of keys beginning with old, which lie from old,
+	// included, to the end of the prefix, excluded, in either order.
+
+	end := PrefixEnd(old)
+	lo, hi := l.PosCeiling(key(old)), l.cnt
+	if l.reversed {
+		lo, hi = 0, l.posAfter(key(old))
+		if nil != end {
+			lo = l.posAfter(key(end))
+		}
+	} else if nil != end {
+		hi = l.PosCeiling(key(end))
+	}
+	if hi <= lo {
+		return 0
+	}
+
+	// Splice out the run and rewrite its keys, which keeps it in order.
+
+	seg := l.SpliceOut(lo, hi)
+	for e := seg.front; nil != e; e = e.links[0].to {
+		if str {
+			e.key = string(nu) + e.key.(string)[len(old):]
+		} else {
+			e.key = append(append([]byte{}, nu...), e.key.([]byte)[len(old):]...)
+		}
+		e.score = l.score(e.key)
+	}
+	if l.fits(seg) {
+		l.SpliceIn(seg)
+		return hi - lo
+	}
+
+	// Relink the entries oldest first, so that entries with equal keys
+	// keep their relative order.
+
+	run := make([]*Element, 0, hi-lo)
+	for e := seg.front; nil != e; e = e.links[0].to {
+		run = append(run, e)
+	}
+	for i := len(run) - 1; i >= 0; i-- {
+		l.link(run[i], false)
+	}
+	return hi - lo
+}
+
+// A Seq is a keyless sequence of values addressed purely by position,
+// sharing the width machinery of T.  Unlike container/list, any position
+// can be read, inserted, or removed in O(log(N)) time.
+//
+type Seq struct {
+	l *T
+}
+
+// NewSeq returns a new, empty Seq.
+//
+func NewSeq() *Seq {
+	// All keys compare equal, so the list is trivially in order.
+	unordered := func(key interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		return func(a, b interface{}) bool { return false }, func(a interface{}) float64 { return 0 }
+	}
+	return &Seq{newT(unordered, false)}
+}
+
+// Len returns the number of values in the sequence in O(1) time.
+//
+func (q *Seq) Len() int { return q.l.cnt }
+
+// Front returns the first element of the sequence, or nil.  Elements of a
+// Seq have nil keys.
+//
+func (q *Seq) Front() *Element { return q.l.Front() }
+
+// ElementN returns the element at position index, or nil, in
+// O(log(index)) time.
+//
+func (q *Seq) ElementN(index int) *Element {
+	if index < 0 {
+		return nil
+	}
+	return q.l.ElementN(index)
+}
+
+// GetN returns the value at position index in O(log(index)) time.
+// It panics if the position is out of range.
+//
+func (q *Seq) GetN(index int) interface{} {
+	return q.mustElementN("GetN", index).Value
+}
+
+// SetN sets the value at position index in O(log(index)) time, and
+// returns the sequence.  It panics if the position is out of range.
+//
+func (q *Seq) SetN(index int, value interface{}) *Seq {
+	e := q.mustElementN("SetN", index)
+	var prev []prev
+	if nil != q.l.agg || nil != q.l.oplog {
+		// All keys are equal, so find the predecessors by position.
+		prev = q.l.prevsN(index)
+	}
+	q.l.setValueAt(e, value, prev)
+	return q
+}
+
+// InsertN inserts value at position index, shifting later values up one
+// position, in O(log(N)) time, and returns the sequence.  Index may be
+// Len() to append.  It panics if the position is out of range.
+//
+func (q *Seq) InsertN(index int, value interface{}) *Seq {
+	if index < 0 || q.l.cnt < index {
+		q.l.panicf("InsertN position %d out of bounds for length %d", index, q.l.cnt)
+	}
+	q.l.linkN(index, &Element{nil, value, 0, nil, 0})
+	return q
+}
+
+// RemoveN removes the element at position index, shifting later values
+// down one position, in O(log(N)) time, and returns it or nil.
+//
+func (q *Seq) RemoveN(index int) *Element {
+	if index < 0 {
+		return nil
+	}
+	return q.l.RemoveN(index)
+}
+
+// WithWidths makes each value of the sequence occupy width(value) units,
+// such as the bytes of a chunk of text, rather than one position, and
+// returns the sequence.  The sequence is then a rope: Width, Offset,
+// IndexAt and RemoveAt address values by cumulative offset in O(log(N))
+// time.  Widths must not be negative, and values must be changed only by
+// SetN, which keeps the widths up to date.  Building the widths takes
+// O(N) time.
+//
+func (q *Seq) WithWidths(width func(value interface{}) int) *Seq {
+	q.l.WithMonoid(0, func(v interface{}) interface{} {
+		w := width(v)
+		if w < 0 {
+			q.l.panicf("width %d of %v is negative", w, v)
+		}
+		return w
+	}, func(a, b interface{}) interface{} { return a.(int) + b.(int) })
+	return q
+}
+
+// Width returns the total width of the values of the sequence in
+// O(log(N)) time.  It panics unless the sequence has widths, as from
+// WithWidths.
+//
+func (q *Seq) Width() int {
+	q.mustWidths("Width")
+	return q.Offset(q.l.cnt)
+}
+
+// Offset returns the total width of the values before position index,
+// which is where the value at index starts, in O(log(N)) time.  It panics
+// unless the sequence has widths, or if the position is out of range.
+//
+func (q *Seq) Offset(index int) int {
+	q.mustWidths("Offset")
+	if index < 0 || q.l.cnt < index {
+		q.l.panicf("Offset position %d out of bounds for length %d", index, q.l.cnt)
+	}
+	if 0 == index {
+		return 0
+	}
+	return q.l.aggregateFrom(&q.l.links, len(q.l.links)-1, -1, 0, index).(int)
+}
+
+// IndexAt returns the position of the value spanning offset, and the
+// offset at which that value starts, in O(log(N)) time.  Values of width 0
+// span no offsets.  If offset is at least Width(), index is Len() and start
+// is Width().  IndexAt panics unless the sequence has widths, or if offset
+// is negative.
+//
+func (q *Seq) IndexAt(offset int) (index, start int) {
+	q.mustWidths("IndexAt")
+	if offset < 0 {
+		q.l.panicf("IndexAt offset %d is negative", offset)
+	}
+
+	// Follow each link whose values all end at or before offset.
+
+	links, pos := &q.l.links, -1
+	for level := len(q.l.links) - 1; level >= 0; level-- {
+		for ln := &(*links)[level]; nil != ln.to && start+ln.agg.(int) <= offset; ln = &(*links)[level] {
+			start += ln.agg.(int)
+			pos += ln.width
+			links = &ln.to.links
+		}
+	}
+	return pos + 1, start
+}
+
+// RemoveAt removes the value spanning offset, as found by IndexAt, in
+// O(log(N)) time, and returns its element, or nil if offset is at least
+// Width().
+//
+func (q *Seq) RemoveAt(offset int) *Element {
+	index, _ := q.IndexAt(offset)
+	return q.RemoveN(index)
+}
+
+// Function mustWidths panics on behalf of method op unless the sequence
+// has widths.
+//
+func (q *Seq) mustWidths(op string) {
+	if nil == q.l.agg {
+		q.l.panicf("%s called on a sequence without WithWidths", op)
+	}
+}
+
+// Function mustElementN returns the element at position index, or panics
+// on behalf of method op.
+//
+func (q *Seq) mustElementN(op string, index int) *Element {
+	e := q.ElementN(index)
+	if nil == e {
+		q.l.panicf("%s position %d out of bounds for length %d", op, index, q.l.cnt)
+	}
+	return e
+}
+
+// A SortedSet is a set of distinct keys, ordered as by New, supporting
+// membership and rank queries in O(log(N)) time.  It shares the element
+// representation of T, so each member still carries an unused Value.
+//
+type SortedSet struct {
+	l *T
+}
+
+// NewSortedSet returns a new, empty SortedSet.
+//
+func NewSortedSet() *SortedSet { return &SortedSet{New()} }
+
+// Len returns the number of keys in the set in O(1) time.
+//
+func (s *SortedSet) Len() int { return s.l.cnt }
 
-// New returns a new skiplist in O(1) time.
-// The list will be sorted from least to greatest key.
+// Add adds key to the set, reporting whether it was absent.
 //
-func New() *T {
-	nu := &T{}
+func (s *SortedSet) Add(key interface{}) bool { return s.l.InsertUnique(key, nil) }
 
-	// Seed a private random number generator for reproducibility.
+// Remove removes key from the set, reporting whether it was present.
+//
+func (s *SortedSet) Remove(key interface{}) bool { return nil != s.l.Remove(key) }
 
-	nu.rng = rand.New(rand.NewSource(42))
+// Contains returns true iff key is in the set.
+//
+func (s *SortedSet) Contains(key interface{}) bool { return nil != s.l.Element(key) }
 
-	// Arrange to set nu.less and nu.score the first time either is called.
-	// We can't do it here because we can't infer the key type until the first
-	// key is inserted.
+// Rank returns the position of key in the set, counting from 0, or -1 if
+// key is absent.
+//
+func (s *SortedSet) Rank(key interface{}) int { return s.l.Pos(key) }
 
-	nu.less = func(a, b interface{}) bool {
-		nu.less, nu.score = ordinal.Fns(a)
-		return nu.less(a, b)
+// KeyN returns the key at position rank, with ok true, or nil and false if
+// there is no such position.
+//
+func (s *SortedSet) KeyN(rank int) (key interface{}, ok bool) {
+	if rank < 0 {
+		return nil, false
 	}
-	nu.score = func(a interface{}) float64 {
-		nu.less, nu.score = ordinal.Fns(a)
-		return nu.score(a)
+	if e := s.l.ElementN(rank); nil != e {
+		return e.key, true
 	}
-	return nu
+	return nil, false
 }
 
-// NewDescending is like New, except keys are sorted from greatest to least.
+// DeleteRank removes the key at position rank, returning it with ok true,
+// or nil and false if there is no such position.
 //
-func NewDescending() *T {
-	nu := &T{}
-
-	// Seed a private random number generator for reproducibility.
-
-	nu.rng = rand.New(rand.NewSource(42))
-
-	// Arrange to set nu.less and nu.score the first time either is called.
-	// We can't do it here because we can't infer the key type until the first
-	// key is inserted.
-
-	nu.less = func(a, b interface{}) bool {
-		nu.less, nu.score = ordinal.FnsReversed(a)
-		return nu.less(a, b)
+func (s *SortedSet) DeleteRank(rank int) (key interface{}, ok bool) {
+	if rank < 0 {
+		return nil, false
 	}
-	nu.score = func(a interface{}) float64 {
-		nu.less, nu.score = ordinal.FnsReversed(a)
-		return nu.score(a)
+	if e := s.l.RemoveN(rank); nil != e {
+		return e.key, true
 	}
-	return nu
+	return nil, false
 }
 
-// Return the first list element in O(1) time.
+// A KeyFuncList is an ascending list of values ordered by keys derived from
+// the values themselves, so keys need not be stored separately.
 //
-func (l *T) Front() *Element {
-	if len(l.links) == 0 {
-		return nil
-	}
-	return l.links[0].to
+type KeyFuncList struct {
+	l *T
 }
 
-// Insert a {key,value} pair in the skiplist, optionally replacing the youngest previous entry.
+// probe wraps a key being searched for in a KeyFuncList, distinguishing it
+// from the stored values.
 //
-func (l *T) insert(key interface{}, value interface{}, replace bool) *T {
-	l.grow()
-	s := l.score(key)
-	prev, pos := l.prevs(key, s)
-	next := prev[0].link.to
-	if replace && nil != next && s == next.score &&
-		!l.less(key, next.key) && !l.less(next.key, key) {
+type probe struct{ key interface{} }
 
-		l.remove(prev, next)
-	}
-	nuLevels := l.randLevels(len(l.links))
-	nu := &Element{key, value, s, make([]link, nuLevels)}
-	for level := range prev {
-		if level < nuLevels {
-			if level == 0 {
-				// At the bottom level, simply link in the new Element of width 1
-				to := prev[level].link.to
-				prev[level].link.to = nu
-				nu.links[level].width = 1
-				nu.links[level].to = to
-				continue
-			}
-			// Link in the new element.
-			end := prev[level].pos + prev[level].link.width + 1
-			nu.links[level].to = prev[level].link.to
-			nu.links[level].width = end - pos
-			prev[level].link.to = nu
-			prev[level].link.width = pos - prev[level].pos
-			continue
+// NewByKeyFunc returns a new, empty KeyFuncList that orders values by
+// extract(value), which must be a key type accepted by New.  Keys such as
+// SlowKey all score alike, so, as for NewFunc, RangeByScore panics on the
+// underlying list.
+//
+func NewByKeyFunc(extract func(value interface{}) interface{}) *KeyFuncList {
+	key := func(x interface{}) interface{} {
+		if p, ok := x.(probe); ok {
+			return p.key
 		}
-		// Higher levels just get a width adjustment.
-		prev[level].link.width += 1
+		return extract(x)
 	}
-	return l
+	l := newT(func(x interface{}) (func(a, b interface{}) bool, func(a interface{}) float64) {
+		less, score := keyFns(key(x))
+		return func(a, b interface{}) bool { return less(key(a), key(b)) },
+			func(a interface{}) float64 { return score(key(a)) }
+	}, false)
+	l.unscored = true
+	return &KeyFuncList{l}
 }
 
-// Insert a {key,value} pair into the skip list in O(log(N)) time.
+// Len returns the number of values in the list in O(1) time.
 //
-func (l *T) Insert(key interface{}, value interface{}) *T {
-	return l.insert(key, value, false)
-}
+func (k *KeyFuncList) Len() int { return k.l.cnt }
 
-// Get returns the value corresponding to key in the table in O(log(N)) time.
-// If there is no corresponding value, nil is returned.
-// If there are multiple corresponding values, the youngest is returned.
+// Insert inserts value in O(log(N)) time, and returns the list.
 //
-// If the list might contain an nil value, you may want to use GetOk instead.
+func (k *KeyFuncList) Insert(value interface{}) *KeyFuncList {
+	k.l.Insert(value, nil)
+	return k
+}
+
+// Set inserts value in O(log(N)) time, replacing the youngest value with
+// the same key, if any, and returns the list.
 //
-func (l *T) Get(key interface{}) (value interface{}) {
-	e, _ := l.ElementPos(key)
-	if nil == e {
-		return nil
-	}
-	return e.Value
+func (k *KeyFuncList) Set(value interface{}) *KeyFuncList {
+	k.l.Set(value, nil)
+	return k
 }
 
-// GetOk returns the value corresponding to key in the table in O(log(N)) time.
-// The return value ok is true iff the key was present.
-// If there is no corresponding value, nil and false are returned.
-// If there are multiple corresponding values, the youngest is returned.
+// Get returns the youngest value whose key is key, with ok true, in
+// O(log(N)) time.  If there is none, nil and false are returned.
 //
-func (l *T) GetOk(key interface{}) (value interface{}, ok bool) {
-	e, _ := l.ElementPos(key)
-	if nil == e {
-		return nil, false
+func (k *KeyFuncList) Get(key interface{}) (value interface{}, ok bool) {
+	if e := k.l.Element(probe{key}); nil != e {
+		return e.key, true
 	}
-	return e.Value, true
+	return nil, false
 }
 
-// GetAll returns all values coresponding to key in the list, starting with the youngest.
-// If no value corresponds, an empty slice is returned.
-// O(log(N)+V) time is required, where M is the number of values returned.
+// Remove removes the youngest value whose key is key, returning it with ok
+// true, in O(log(N)) time.  If there is none, nil and false are returned.
 //
-func (l *T) GetAll(key interface{}) (values []interface{}) {
-	s := l.score(key)
-	prevs, _ := l.prevs(key, s)
-	e := prevs[0].link.to
-	for nil != e && e.score == s && !l.less(key, e.key) {
-		values = append(values, e.Value)
-		e = e.links[0].to
+func (k *KeyFuncList) Remove(key interface{}) (value interface{}, ok bool) {
+	if e := k.l.Remove(probe{key}); nil != e {
+		return e.key, true
 	}
-	return values
+	return nil, false
 }
 
-// Insert a {key,value} pair into the skip list in O(log(N)) time, replacing the youngest entry
-// for key, if any.
+// All returns an iterator, in the form of an iter.Seq, over the values of
+// the list, in key order.
 //
-func (l *T) Set(key interface{}, value interface{}) *T {
-	return l.insert(key, value, true)
+func (k *KeyFuncList) All() func(yield func(value interface{}) bool) {
+	return k.l.AllKeys()
 }
 
-// Function remove removes Element elem from a list.  Parameter prevs must be
-// the precomputed predecessor list for the element.
+// Function panicf panics with a message identifying the list by its name and labels.
 //
-func (l *T) remove(prev []prev, elem *Element) *Element {
-	// At the bottom level, simply unlink the element.
-	prev[0].link.to = elem.links[0].to
-	// Unlink any higher linked levels.
-	level := 1
-	levels := len(l.links)
-	for ; level < levels && prev[level].link.to == elem; level++ {
-		prev[level].link.to = elem.links[level].to
-		prev[level].link.width += elem.links[level].width - 1
+func (l *T) panicf(format string, a ...interface{}) {
+	id := "skiplist"
+	if "" != l.name {
+		id += " " + strconv.Quote(l.name)
 	}
-	// Adjust widths at higher levels
-	for ; level < levels; level++ {
-		prev[level].link.width -= 1
+	if 0 != len(l.labels) {
+		var labels []string
+		for k, v := range l.labels {
+			labels = append(labels, k+"="+strconv.Quote(v))
+		}
+		sort.Strings(labels)
+		id += " {" + strings.Join(labels, ", ") + "}"
 	}
-	l.shrink()
-	return elem
+	panic(id + ": " + fmt.Sprintf(format, a...))
 }
 
-// Remove the youngest Element associate with Key, if any, in O(log(N)) time.
-// Return the removed element or nil.
+// Function mutating enforces the ownership recorded by CheckOwner, if any,
+// and must be called by every operation that modifies the list.
 //
-func (l *T) Remove(key interface{}) *Element {
-	s := l.score(key)
-	prevs, _ := l.prevs(key, s)
-	// Verify there is a matching entry to remove.
-	elem := l.prev[0].link.to
-	if elem == nil || s != elem.score || s == elem.score && l.less(key, elem.key) {
-		return nil
+func (l *T) mutating() {
+	if !l.guard {
+		return
+	}
+	id := goroutineID()
+	if 0 == l.owner {
+		l.owner = id
+	} else if id != l.owner {
+		l.panicf("modified by goroutine %d but owned by goroutine %d; guard the list with a lock", id, l.owner)
 	}
-	return l.remove(prevs, elem)
 }
 
-// Remove the specified element from the table, in O(log(N)) time.
-// If the element is one of M multiple entries for the key, and additional O(M) time is required.
-// This is useful for removing a specific element in a multimap, or removing elements during iteration.
+// Function goroutineID returns the id of the calling goroutine, parsed
+// from the "goroutine N [state]:" header of its stack trace.
 //
-func (l *T) RemoveElement(e *Element) *Element {
-
-	// Find the first element in the multimap group.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	id, _ := strconv.ParseUint(string(b[:bytes.IndexByte(b, ' ')]), 10, 64)
+	return id
+}
 
-	k := e.key
-	s := l.score(k)
-	prevs, pos := l.prevs(k, s)
+// Function before returns true iff element e sorts before key, which has score s.
+//
+func (l *T) before(e *Element, key interface{}, s float64) bool {
+	return e.score < s || e.score == s && l.less(e.key, key)
+}
 
-	// Find the position of the matching entry within the multimap group.
+// Function levelsFor returns the number of levels in a list of cnt
+// elements, which is the bit length of cnt.
+//
+func levelsFor(cnt int) (levels int) {
+	for n := cnt; n > 0; n >>= 1 {
+		levels++
+	}
+	return levels
+}
 
-	for match := prevs[0].link.to; nil != match && match != e; match = match.Next() {
-		pos++
+// Function resize discards the links of the list and sizes it for cnt
+// elements, with the bottom level linked to front.  The list must then
+// be relinked.
+//
+func (l *T) resize(cnt int, front *Element) {
+	l.mutating()
+	l.dropFinger()
+	levels := levelsFor(cnt)
+	l.cnt = cnt
+	l.links = make([]link, levels)
+	l.prev = make([]prev, levels)
+	if levels > 0 {
+		l.links[0].to = front
 	}
+}
 
-	// Adjust prevs to be relative to the element, not relative to the start of the group.
+// Function fill makes l hold the cnt elements chained at the bottom level
+// from front, as for rebuild, then evicts any beyond WithMaxLen.
+//
+func (l *T) fill(front *Element, cnt int) *T {
+	l.rebuild(front, cnt)
+	l.evict()
+	return l
+}
 
-	levels := len(prevs)
-	for level := 0; level < levels; level++ {
-		for l := prevs[level]; l.pos+l.link.width < pos; {
-			prevs[level].pos = l.pos + l.link.width
-			prevs[level].link = &l.link.to.links[level]
+// Function rebuild makes l hold the cnt elements chained at the bottom
+// level from front, in O(cnt) time, discarding any previous links.  Each
+// element is given the level of a perfectly balanced list for the
+// promotion probability of the list, up to any level cap: with p of 1/4,
+// every fourth element reaches the second level, and so on.
+//
+func (l *T) rebuild(front *Element, cnt int) {
+	l.resize(cnt, front)
+	limit := len(l.links)
+	if 0 < l.maxLevel && l.maxLevel < limit {
+		limit = l.maxLevel
+	}
+	base := 2
+	if 0 != l.p {
+		base = max(2, int(math.Round(1/l.p)))
+	}
+	pos := 0
+	for e := front; nil != e; pos++ {
+		next := e.links[0].to
+		levels := 1
+		for n := pos + 1; 0 == n%base && levels < limit; n /= base {
+			levels++
 		}
+		if levels <= cap(e.links) {
+			e.links = e.links[:levels]
+		} else {
+			e.links = make([]link, levels)
+		}
+		e.links[0].to = next
+		if 0 == e.seq {
+			l.stamp(e)
+		}
+		e = next
+	}
+	l.relink()
+	if nil != l.keys {
+		l.WithKeyIndex()
+	}
+	if nil != l.agg {
+		l.aggregateAll()
 	}
+}
 
-	// Remove the element.
+// Function relink rebuilds every level of the list above the bottom level,
+// and all link widths, from the bottom-level chain and the level of each
+// element, in O(N) time.
+//
+func (l *T) relink() {
+	levels := len(l.links)
+	for level := range l.prev {
+		l.prev[level] = prev{&l.links[level], &l.links, -1}
+	}
+	pos := 0
+	for e := l.Front(); nil != e; e = e.links[0].to {
+		if len(e.links) > levels {
+			e.links = e.links[:levels]
+		}
+		for level := range e.links {
+			p := &l.prev[level]
+			p.link.to = e
+			p.link.width = pos - p.pos
+			p.link, p.links = &e.links[level], &e.links
+			p.pos = pos
+		}
+		pos++
+	}
+	for level := range l.prev {
+		p := &l.prev[level]
+		p.link.to = nil
+		p.link.width = l.cnt - p.pos
+	}
+}
 
-	return l.remove(prevs, e)
+// Function stamp gives element e the next insertion sequence number of the list.
+//
+func (l *T) stamp(e *Element) {
+	l.seq++
+	e.seq = l.seq
 }
 
-// RemoveN removes any element at position pos in O(log(N)) time,
-// returning it or nil.
+// Function remember updates auxiliary state for element e, which was just
+// linked into the list at position pos.
 //
-func (l *T) RemoveN(index int) *Element {
-	if index >= l.cnt {
-		return nil
+func (l *T) remember(e *Element, pos int) {
+	for i := range l.tracked {
+		if e.score < l.tracked[i].score {
+			l.tracked[i].below++
+		}
+	}
+	if nil != l.hash {
+		l.index(e)
+	}
+	if nil != l.bloom {
+		l.bloom.add(e.key, 1)
+	}
+	if nil != l.keys {
+		// e is the youngest entry for its key unless it follows one.
+		k := mapKey(e.key)
+		if cur, ok := l.keys[k]; !ok || cur == e.links[0].to {
+			l.keys[k] = e
+		}
+	}
+	if nil != l.watches {
+		l.notify(Inserted, e)
+	}
+	if nil != l.oplog {
+		l.oplog.add(Inserted, e, pos)
+	}
+	if nil != l.onInsert {
+		l.onInsert(e)
 	}
-	prevs := l.prevsN(index)
-	elem := prevs[0].link.to
-	return l.remove(prevs, elem)
 }
 
-// Element returns the youngest list element for key and its position,
-// If there is no match, nil and -1 are returned.
-//
-// Consider using Get or GetAll instead if you only want Values.
+// Function forget updates auxiliary state for element e, which was just
+// unlinked from position pos of the list.
 //
-func (l *T) ElementPos(key interface{}) (e *Element, pos int) {
-	s := l.score(key)
-	prev, pos := l.prevs(key, s)
-	elem := prev[0].link.to
-	if elem == nil || s < elem.score || s == elem.score && l.less(key, elem.key) {
-		return nil, -1
+func (l *T) forget(e *Element, pos int) {
+	for i := range l.tracked {
+		if e.score < l.tracked[i].score {
+			l.tracked[i].below--
+		}
+	}
+	if nil != l.hash {
+		l.unindex(e)
+	}
+	if nil != l.bloom {
+		l.bloom.add(e.key, -1)
+	}
+	if d, ok := l.ttls[e]; ok {
+		l.expiry.RemoveElement(d)
+		delete(l.ttls, e)
+	}
+	if nil != l.keys {
+		k := mapKey(e.key)
+		if l.keys[k] == e {
+			if next := e.links[0].to; nil != next && mapKey(next.key) == k {
+				l.keys[k] = next
+			} else {
+				delete(l.keys, k)
+			}
+		}
+	}
+	if nil != l.watches {
+		l.notify(Removed, e)
+	}
+	if nil != l.oplog {
+		l.oplog.add(Removed, e, pos)
+	}
+	if nil != l.onRemove {
+		l.onRemove(e)
 	}
-	return elem, pos
 }
 
-// Element returns the youngest list element for key,
-// without modifying the list, in O(log(N)) time.
-// If there is no match, nil is returned.
+// Function setValue sets the value of element e, which is in the list,
+// keeping the value index and aggregates, if any, up to date.
 //
-func (l *T) Element(key interface{}) (e *Element) {
-	e, _ = l.ElementPos(key)
-	return e
+func (l *T) setValue(e *Element, v interface{}) {
+	l.setValueAt(e, v, nil)
 }
 
-// ElementPos returns the position of the youngest list element for key,
-// without modifying the list, in O(log(N)) time.
-// If there is no match, -1 is returned.
+// Function setValueAt is like setValue, given the predecessor list prev
+// for e, or nil to search for it if it is needed.
 //
-// Consider using Get or GetAll instead if you only want Values.
+func (l *T) setValueAt(e *Element, v interface{}, prev []prev) {
+	l.mutating()
+	l.guardValue(v)
+	if nil != l.hash {
+		l.unindex(e)
+	}
+	e.Value = v
+	if nil != l.hash {
+		l.index(e)
+	}
+	if nil == prev && (nil != l.agg || nil != l.oplog) {
+		prev = l.prevsElement(e)
+	}
+	if nil != l.agg {
+		l.reaggregate(prev, nil)
+	}
+	if nil != l.watches {
+		l.notify(Updated, e)
+	}
+	if nil != l.oplog {
+		l.oplog.add(Updated, e, prev[0].pos+1)
+	}
+}
+
+// Function index adds element e to the value index.
 //
-func (l *T) Pos(key interface{}) (pos int) {
-	_, pos = l.ElementPos(key)
-	return pos
+func (l *T) index(e *Element) {
+	h := l.hash(e.Value)
+	l.values[h] = append(l.values[h], e)
 }
 
-// Len returns the number of elements in the skiplist.
+// Function unindex removes element e from the value index.
 //
-func (l *T) Len() int {
-	return l.cnt
+func (l *T) unindex(e *Element) {
+	h := l.hash(e.Value)
+	bucket := l.values[h]
+	for i, match := range bucket {
+		if match == e {
+			bucket[i] = bucket[len(bucket)-1]
+			bucket = bucket[:len(bucket)-1]
+			break
+		}
+	}
+	if 0 == len(bucket) {
+		delete(l.values, h)
+	} else {
+		l.values[h] = bucket
+	}
 }
 
-// ElementN returns the Element at position pos in the skiplist, in O(log(index)) time.
-// If no such entry exists, nil is returned.
+// ElementNFrom returns the element delta positions after element e, or nil
+// if there is none or e is not in the list, in O(log(N)) time, plus O(M)
+// if e is one of M entries for its key.  Delta must not be negative.  The
+// towers of elements may keep stale levels after removals, so the search
+// starts from the head rather than from e.
 //
-func (l *T) ElementN(index int) *Element {
-	if index >= l.cnt {
+func (l *T) ElementNFrom(e *Element, delta int) *Element {
+	if delta < 0 || nil == e {
 		return nil
 	}
-	prev := l.prevsN(index)
-	return prev[0].link.to
+	prevs := l.prevsElement(e)
+	if nil == prevs {
+		return nil
+	}
+	return l.ElementN(prevs[0].pos + 1 + delta)
 }
 
 // Function grow increments the list count and increment the number of
 // levels on power-of-two counts.
 //
 func (l *T) grow() {
+	l.mutating()
 	l.cnt++
 	if l.cnt&(l.cnt-1) == 0 {
-		l.links = append(l.links, link{nil, l.cnt})
+		l.links = append(l.links, link{width: l.cnt})
 		l.prev = append(l.prev, prev{})
 	}
 }
 
+// A prev is a predecessor link found by a search, the tower holding it,
+// and the position of the element owning the tower, or -1 for the head.
+//
 type prev struct {
-	link *link
-	pos  int
+	link  *link
+	links *[]link
+	pos   int
 }
 
 // Return the previous links to modify, and the insertion position.
 //
 func (l *T) prevs(key interface{}, s float64) ([]prev, int) {
 	levels := len(l.links)
+	if 0 == levels {
+		// An empty list has no links, so return a lone link to nowhere.
+		return []prev{{&link{}, nil, -1}}, 0
+	}
 	prev := l.prev
 	links := &l.links
 	pos := -1
-	for level := levels - 1; level >= 0; level-- {
+	top := levels - 1
+	f := l.finger
+	if nil != f && len(f.links) != levels {
+		f.links, f.pos, f.ok = make([]*[]link, levels), make([]int, levels), false
+	}
+	if nil != f && f.ok && !(s < f.score || s == f.score && l.less(key, f.key)) {
+		// Climb from the finger while the next element is before key.  The
+		// predecessors above are those for the finger.
+		top = 0
+		for top < levels-1 {
+			if to := (*f.links[top])[top].to; nil == to || !l.before(to, key, s) {
+				break
+			}
+			top++
+		}
+		for level := levels - 1; level > top; level-- {
+			prev[level].link, prev[level].links, prev[level].pos = &(*f.links[level])[level], f.links[level], f.pos[level]
+		}
+		links, pos = f.links[top], f.pos[top]
+	}
+	for level := top; level >= 0; level-- {
 		// Find predecessor link at this level
 		for (*links)[level].to != nil && ((*links)[level].to.score < s || (*links)[level].to.score == s && l.less((*links)[level].to.key, key)) {
 			pos += (*links)[level].width
 			links = &(*links)[level].to.links
 		}
 		prev[level].pos = pos
-		prev[level].link = &(*links)[level]
+		prev[level].link, prev[level].links = &(*links)[level], links
+		if nil != f {
+			f.links[level], f.pos[level] = links, pos
+		}
+	}
+	if nil != f {
+		f.key, f.score, f.ok = key, s, true
 	}
 	pos++
 	return prev, pos
@@ -405,27 +4384,33 @@ func (l *T) prevsN(index int) []prev {
 	levels := len(l.links)
 	prev := l.prev
 	links := &l.links
-	pos := 0
+	pos := -1
 	for level := levels - 1; level >= 0; level-- {
 		// Find predecessor link at this level
-		for (*links)[level].to != nil && (pos+(*links)[level].width <= index) {
+		for (*links)[level].to != nil && (pos+(*links)[level].width < index) {
 			pos = pos + (*links)[level].width
 			links = &(*links)[level].to.links
 		}
 		prev[level].pos = pos
-		prev[level].link = &(*links)[level]
+		prev[level].link, prev[level].links = &(*links)[level], links
 	}
 	return prev
 }
 
 // Function randLevels returns a value from N from [0..limit-1] with probability
-// 2^{-n-1}, except the last value is twice as likely.
+// 2^{-n-1}, except the last value is twice as likely.  If WithProbability
+// has set p, the probability is instead (1-p)*p^n, with the remainder
+// going to the last value.
 //
 func (l *T) randLevels(max int) int {
-	levels := 1
-	for r := l.rng.Int63(); 0 == r&1; r >>= 1 {
-		levels++
+	if 0 != l.p {
+		levels := 1
+		for levels < max && float64(l.rng.Uint64()>>11)/(1<<53) < l.p {
+			levels++
+		}
+		return levels
 	}
+	levels := 1 + bits.TrailingZeros64(l.rng.Uint64())
 	if levels > max {
 		return max
 	}
@@ -436,6 +4421,8 @@ func (l *T) randLevels(max int) int {
 // of levels on power-of-two counts.
 //
 func (l *T) shrink() {
+	l.mutating()
+	l.dropFinger()
 	if l.cnt&(l.cnt-1) == 0 {
 		l.links = l.links[:len(l.links)-1]
 		l.prev = l.prev[:len(l.prev)-1]
@@ -446,6 +4433,9 @@ func (l *T) shrink() {
 // Function String prints only the key/value pairs in the skip list.
 //
 func (l *T) String() string {
+	if 0 == l.cnt {
+		return "{}"
+	}
 	s := append([]byte{}, "{"...)
 	for n := l.links[0].to; n != nil; n = n.links[0].to {
 		s = append(s, (n.String() + " ")...)
@@ -520,7 +4510,7 @@ func lessFn(key interface{}) func(a, b interface{}) bool {
 	panic(fmt.Sprintf("skiplist: %T not supported.  Consider adding the SlowKey interface.", key))
 }
 
-// Function lessFn returns the comparison function corresponding to the key type.
+// Function greaterFn returns the reversed comparison function corresponding to the key type.
 //
 func greaterFn(key interface{}, descending bool) func(a, b interface{}) bool {
 	switch key.(type) {
@@ -4,9 +4,15 @@ package skiplist
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"math/rand"
+	randv2 "math/rand/v2"
+	"net"
+	"net/netip"
 	"strings"
 	"testing"
+	"time"
 )
 
 ////////////////////////////////////////////////////////////////
@@ -74,6 +80,94 @@ func TestNewDescending(t *testing.T) {
 	}
 }
 
+func TestNewFromSorted(t *testing.T) {
+	t.Parallel()
+	for n := 0; n < 70; n++ {
+		keys, values := make([]interface{}, n), make([]interface{}, n)
+		for i := range keys {
+			keys[i], values[i] = i/2, i
+		}
+		l, want := NewFromSorted(keys, values), New()
+		for i := n - 1; i >= 0; i-- {
+			want.Insert(keys[i], values[i])
+		}
+		if err := valid(l); err != nil {
+			t.Fatal(n, err)
+		}
+		if l.Len() != n || len(l.links) != len(want.links) || l.String() != want.String() {
+			t.Fatal(n, l, want)
+		}
+		for i := 0; i < n; i++ {
+			if e := l.ElementN(i); e == nil || e.Value != i || l.Pos(e.Key()) != i&^1 {
+				t.Fatal(n, i, e)
+			}
+		}
+		l.Insert(n, n).Remove(0)
+		want.Insert(n, n).Remove(0)
+		if l.Len() != want.Len() || l.String() != want.String() {
+			t.Fatal(n, l, want)
+		}
+	}
+}
+
+func TestNewFromSorted_unsorted(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	NewFromSorted([]interface{}{2, 1}, []interface{}{nil, nil})
+}
+
+func TestT_Clone(t *testing.T) {
+	t.Parallel()
+	for n := 0; n < 40; n += 7 {
+		l := skiplist(0, n).Insert(n/2, -1).TrackBelow(3)
+		c := l.Clone()
+		if err := valid(c); err != nil || c.String() != l.String() || c.visualization() != l.visualization() {
+			t.Fatal(n, err, "\n"+c.visualization(), "\n"+l.visualization())
+		}
+		c.Insert(-1, -1).Remove(0)
+		if l.Len() != n+2 || nil == l.Element(0) || nil != l.Element(-1) || c.CountBelow(3) != l.CountBelow(3) {
+			t.Fatal(n, "clone not independent")
+		}
+	}
+	d := NewDescending().Clone().Insert(1, 1).Insert(2, 2)
+	if d.String() != "{2:2 1:1}" {
+		t.Error(d)
+	}
+	l := New()
+	c := l.Clone().Insert("a", 1)
+	l.Insert(1, 1)
+	if c.String() != "{a:1}" || l.String() != "{1:1}" {
+		t.Error(c, l)
+	}
+}
+
+func TestT_Named(t *testing.T) {
+	t.Parallel()
+	labels := map[string]string{"shard": "3", "dc": "east"}
+	l := skiplist(1, 3).Named("sessions", labels)
+	if l.Name() != "sessions" || l.Labels()["shard"] != "3" || l.Clone().Name() != "sessions" {
+		t.Fail()
+	}
+
+	// Lists do not share labels with the caller or with their copies.
+
+	labels["shard"] = "4"
+	l.Clone().Labels()["dc"] = "west"
+	if l.Labels()["shard"] != "3" || l.Labels()["dc"] != "east" {
+		t.Error(l.Labels())
+	}
+	defer func() {
+		if r := recover(); r != `skiplist "sessions" {dc="east", shard="3"}: SpliceOut range [2,1) out of bounds for length 3` {
+			t.Error(r)
+		}
+	}()
+	l.SpliceOut(2, 1)
+}
+
 func TestT_Front(t *testing.T) {
 	t.Parallel()
 	s := skiplist(1, 3)
@@ -82,97 +176,2170 @@ func TestT_Front(t *testing.T) {
 	}
 }
 
-func TestT_Insert(t *testing.T) {
+func TestT_Insert(t *testing.T) {
+	t.Parallel()
+	if skiplist(1, 10).String() != "{1:2 2:4 3:6 4:8 5:10 6:12 7:14 8:16 9:18 10:20}" {
+		t.Fail()
+	}
+}
+
+func TestT_Get(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 7)
+	if l.Get(0).(int) != 0 || l.Get(4).(int) != 8 || l.Get(7).(int) != 14 {
+		t.Fail()
+	}
+}
+
+func TestT_GetOk(t *testing.T) {
+	t.Parallel()
+	l := skiplist(1, 3)
+	v, ok := l.GetOk(0)
+	if nil != v || false != ok {
+		t.Fail()
+	}
+	v, ok = l.GetOk(1)
+	if 2 != v.(int) || true != ok {
+		t.Fail()
+	}
+	v, ok = l.GetOk(3)
+	if 6 != v.(int) || true != ok {
+		t.Fail()
+	}
+	v, ok = l.GetOk(4)
+	if nil != v || false != ok {
+		t.Fail()
+	}
+}
+
+func TestT_GetAll(t *testing.T) {
+	t.Parallel()
+	l := skiplist(1, 3).Insert(2, 3).Insert(2, 5)
+	a := l.GetAll(0)
+	if 0 != len(a) {
+		t.Fail()
+	}
+	a = l.GetAll(1)
+	if 1 != len(a) || 2 != a[0] {
+		t.Fail()
+	}
+	a = l.GetAll(2)
+	if 3 != len(a) || 5 != a[0] || 3 != a[1] || 4 != a[2] {
+		t.Fail()
+	}
+	a = l.GetAll(3)
+	if 1 != len(a) || 6 != a[0] {
+		t.Fail()
+	}
+	a = l.GetAll(4)
+	if 0 != len(a) {
+		t.Fail()
+	}
+}
+
+func TestT_GetAllElements(t *testing.T) {
+	t.Parallel()
+	l := skiplist(1, 3).Insert(2, 3).Insert(2, 5)
+	if 0 != len(l.GetAllElements(0)) || 0 != len(l.GetAllElements(4)) {
+		t.Fail()
+	}
+	a := l.GetAllElements(2)
+	if 3 != len(a) || 5 != a[0].Value || 3 != a[1].Value || 4 != a[2].Value {
+		t.Fail()
+	}
+	if l.RemoveElement(a[1]) != a[1] || l.String() != "{1:2 2:5 2:4 3:6}" {
+		t.Error(l)
+	}
+	if 0 != len(New().GetAllElements(1)) {
+		t.Error("empty list")
+	}
+}
+
+func TestT_Count(t *testing.T) {
+	t.Parallel()
+	l := skiplist(1, 3).Insert(2, 3).Insert(2, 5)
+	if l.Count(0) != 0 || l.Count(1) != 1 || l.Count(2) != 3 || l.Count(3) != 1 || l.Count(4) != 0 {
+		t.Fail()
+	}
+}
+
+func TestT_Set(t *testing.T) {
+	t.Parallel()
+	l := skiplist(1, 3)
+	l.Set(2, 2)
+	a := l.GetAll(2)
+	if 1 != len(a) || a[0].(int) != 2 {
+		t.Fail()
+	}
+}
+
+func TestT_Merge(t *testing.T) {
+	t.Parallel()
+	for _, sizes := range [][2]int{{0, 0}, {0, 5}, {5, 0}, {9, 20}, {33, 3}} {
+		a, b, want := New().TrackBelow(4), New(), New()
+		for i := 0; i < sizes[0]; i++ {
+			a.Insert(i%7, "a")
+			want.Insert(i%7, "a")
+		}
+		for i := 0; i < sizes[1]; i++ {
+			b.Insert(i%5, "b")
+		}
+		for i := sizes[1] - 1; i >= 0; i-- {
+			want.Insert(b.ElementN(i).Key(), "b")
+		}
+		a.Merge(b)
+		if err := valid(a); err != nil {
+			t.Fatal(sizes, err)
+		}
+		if a.String() != want.String() || b.Len() != 0 || b.String() != "{}" {
+			t.Fatal(sizes, a, want)
+		}
+		below := a.CountBelow(4)
+		if a.tracked = nil; below != a.CountBelow(4) {
+			t.Fatal(sizes, below)
+		}
+		b.Insert(1, "c")
+		if err := valid(b); err != nil || b.Get(1) != "c" {
+			t.Fatal(sizes, err)
+		}
+	}
+}
+
+func TestT_Remove(t *testing.T) {
+	t.Parallel()
+	s := skiplist(0, 10)
+	if s.Remove(-1) != nil || s.Remove(11) != nil {
+		t.Error("Removing nonexistant key should fail.")
+	}
+	for i := range rand.Perm(11) {
+		e := s.Remove(i)
+		if e == nil {
+			t.Error("nil")
+		}
+		if e.Key().(int) != i {
+			t.Error("bad key")
+		}
+		if e.Value.(int) != 2*i {
+			t.Error("bad value")
+		}
+	}
+	if s.Len() != 0 {
+		t.Error("nonzero len")
+	}
+}
+
+func TestT_RemoveNext(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9)
+	for e := l.Front(); nil != e; {
+		var removed *Element
+		if removed, e = l.RemoveNext(e.Key()); removed == nil {
+			t.Fatal("nothing removed")
+		}
+		if nil != e && e != l.Front() {
+			t.Fatal("wrong next")
+		}
+	}
+	if l.Len() != 0 {
+		t.Error(l)
+	}
+	if removed, next := l.RemoveNext(1); removed != nil || next != nil {
+		t.Error("removed from empty list")
+	}
+}
+
+func TestT_TryInsert(t *testing.T) {
+	t.Parallel()
+	l := New()
+	if err := l.TryInsert(struct{}{}, 1); err != ErrUnsupportedKeyType || l.Len() != 0 {
+		t.Error(err)
+	}
+	if err := l.TryInsert(1, 1); nil != err {
+		t.Error(err)
+	}
+	if err := l.TrySet("one", 1); err != ErrKeyTypeMismatch || l.Len() != 1 {
+		t.Error(err)
+	}
+	if v, ok, err := l.TryGet(1); nil != err || !ok || v != 1 {
+		t.Error(v, ok, err)
+	}
+	if _, _, err := l.TryGet(1.0); err != ErrKeyTypeMismatch {
+		t.Error(err)
+	}
+	if err := l.Clear().TrySet(2, 2); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestT_StrictKeys(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9).StrictKeys()
+	func() {
+		defer func() {
+			if r := recover(); r != "skiplist: key a of type string cannot be ordered with keys of type int" {
+				t.Error(r)
+			}
+		}()
+		l.Insert("a", 1)
+	}()
+	if err := valid(l); nil != err || l.Len() != 10 {
+		t.Error(l, err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "skiplist: key {} of type struct {} is not supported" {
+				t.Error(r)
+			}
+		}()
+		New().StrictKeys().Upsert(struct{}{}, nil)
+	}()
+}
+
+func TestT_WithNilKeys(t *testing.T) {
+	t.Parallel()
+	l := New().WithNilKeys(NilFirst).Insert(nil, "sentinel").Insert(2, 2).Insert(1, 1)
+	if err := valid(l); nil != err || l.String() != "{<nil>:sentinel 1:1 2:2}" || l.Get(nil) != "sentinel" {
+		t.Error(l, err)
+	}
+	d := NewDescending().WithNilKeys(NilFirst).Insert(1, 1).Insert(nil, "sentinel").Insert(2, 2)
+	if d.String() != "{<nil>:sentinel 2:2 1:1}" {
+		t.Error(d)
+	}
+	r := New().WithNilKeys(NilRejected)
+	if err := r.TryInsert(nil, 0); err != ErrNilKey || r.Len() != 0 {
+		t.Error(err, r)
+	}
+	func() {
+		defer func() {
+			if x := recover(); x != "skiplist: nil key rejected" {
+				t.Error(x)
+			}
+		}()
+		r.Insert(nil, 0)
+	}()
+}
+
+func TestT_WithScorePrefix(t *testing.T) {
+	t.Parallel()
+	keys := []string{"", "a", "http://", "http://a", "http://a/b", "http://b", "http://\xff", "http:/", "https://", "z"}
+	for _, l := range []*T{New().WithScorePrefix("http://"), NewDescending().WithScorePrefix("http://")} {
+		for _, i := range rand.Perm(len(keys)) {
+			l.Insert(keys[i], i)
+		}
+		if err := valid(l); nil != err || l.Len() != len(keys) {
+			t.Error(l, err)
+		}
+		for i, k := range keys {
+			if l.Get(k) != i {
+				t.Error(l, k)
+			}
+		}
+	}
+	if prefixScore("p/", "p/a") == prefixScore("p/", "p/b") {
+		t.Error("prefixed keys score alike")
+	}
+}
+
+func TestT_WithoutScores(t *testing.T) {
+	t.Parallel()
+	l := New().WithoutScores()
+	for _, i := range rand.Perm(100) {
+		l.Insert(i, i)
+	}
+	if err := valid(l); nil != err || l.Get(42) != 42 || l.Front().score != 0 || l.ElementN(99).score != 0 {
+		t.Error(l, err)
+	}
+}
+
+// A scoredKey implements FastKey.
+//
+type scoredKey int
+
+func (a scoredKey) Less(b interface{}) bool { return a < b.(scoredKey) }
+func (a scoredKey) Score() float64          { return float64(a) }
+
+func TestNewDescending_interfaceKeys(t *testing.T) {
+	t.Parallel()
+	fast, slow := New(), NewDescending()
+	scored, fastD := NewDescending(), NewDescending()
+	for _, i := range rand.Perm(20) {
+		fast.Insert(&FastType{i, 0}, i)
+		fastD.Insert(&FastType{i, 0}, i)
+		slow.Insert(&MyType{i, 0}, i)
+		scored.Insert(scoredKey(i), i)
+	}
+	for _, l := range []*T{fast, slow, scored, fastD} {
+		if err := valid(l); nil != err {
+			t.Error(err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if fast.ElementN(i).Value != i || fastD.ElementN(i).Value != 19-i || slow.ElementN(i).Value != 19-i || scored.ElementN(i).Value != 19-i {
+			t.Error(i)
+		}
+	}
+	if s := scored.Front().score; s != -19 {
+		t.Error(s)
+	}
+	if s := fastD.Front().score; s != -19 {
+		t.Error(s)
+	}
+}
+
+func TestNewDescending_bytes(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewSource(1))
+	for _, str := range []bool{false, true} {
+		up, down := New(), NewDescending()
+		for i := 0; i < 500; i++ {
+			b := make([]byte, rng.Intn(12))
+			for j := range b {
+				b[j] = "ab\x00\xff"[rng.Intn(4)]
+			}
+			var key interface{} = b
+			if str {
+				key = string(b)
+			}
+			up.Insert(key, i)
+			down.Insert(key, i)
+		}
+		if err := valid(down); nil != err {
+			t.Error(err)
+		}
+		for i, e := 0, down.Front(); nil != e; i, e = i+1, e.Next() {
+			u := up.ElementN(up.Len() - 1 - i)
+			if fmt.Sprint(u.key) != fmt.Sprint(e.key) || u.score != -e.score {
+				t.Error(i, u, e)
+			}
+		}
+	}
+}
+
+func TestBigKeys(t *testing.T) {
+	t.Parallel()
+	huge := new(big.Int).Lsh(big.NewInt(1), 2000)
+	ints := []*big.Int{new(big.Int).Neg(huge), big.NewInt(-1), big.NewInt(0), huge, new(big.Int).Add(huge, big.NewInt(1))}
+	rats := []*big.Rat{big.NewRat(-1, 3), big.NewRat(1, 3), new(big.Rat).SetFrac(huge, big.NewInt(3)), new(big.Rat).SetInt(huge)}
+	up, down := New(), NewDescending()
+	for _, i := range rand.Perm(len(ints)) {
+		up.Insert(ints[i], i)
+		down.Insert(ints[i], i)
+	}
+	r := New()
+	for _, i := range rand.Perm(len(rats)) {
+		r.Insert(rats[i], i)
+	}
+	for _, l := range []*T{up, down, r} {
+		if err := valid(l); nil != err {
+			t.Error(err)
+		}
+	}
+	for i := range ints {
+		if up.ElementN(i).Value != i || down.ElementN(i).Value != len(ints)-1-i || up.Get(new(big.Int).Set(ints[i])) != i {
+			t.Error(i)
+		}
+	}
+	for i := range rats {
+		if r.ElementN(i).Value != i {
+			t.Error(r)
+		}
+	}
+}
+
+func TestAddressKeys(t *testing.T) {
+	t.Parallel()
+	addrs := []string{"0.0.0.0", "10.0.0.1", "10.0.0.2", "255.255.255.255", "::", "::ffff:10.0.0.1", "2001:db8::1", "2001:db8::2", "fe80::1", "fe80::1%eth0"}
+	prefixes := []string{"10.0.0.0/8", "10.0.0.0/24", "10.0.1.0/24", "2001:db8::/32", "2001:db8::/48"}
+	a, p, ip := New(), New(), NewDescending()
+	for _, i := range rand.Perm(len(addrs)) {
+		a.Insert(netip.MustParseAddr(addrs[i]), i)
+		if !strings.Contains(addrs[i], "%") {
+			ip.Set(net.ParseIP(addrs[i]), addrs[i])
+		}
+	}
+	a.Insert(netip.Addr{}, -1)
+	for _, i := range rand.Perm(len(prefixes)) {
+		p.Insert(netip.MustParsePrefix(prefixes[i]), i)
+	}
+	for _, l := range []*T{a, p, ip} {
+		if err := valid(l); nil != err {
+			t.Error(err)
+		}
+	}
+	for i := range addrs {
+		if a.ElementN(i+1).Value != i {
+			t.Error(a)
+		}
+	}
+	for i := range prefixes {
+		if p.ElementN(i).Value != i {
+			t.Error(p)
+		}
+	}
+	if v := ip.Get(net.ParseIP("10.0.0.1").To4()); ip.Len() != 8 || v != "10.0.0.1" && v != "::ffff:10.0.0.1" || ip.Front().Value != "fe80::1" {
+		t.Error(ip)
+	}
+}
+
+func TestTuple(t *testing.T) {
+	t.Parallel()
+	keys := []TupleKey{Tuple(), Tuple("a"), Tuple("a", 1), Tuple("a", 2), Tuple("a", 2, 0.5), Tuple("b", -1), Tuple("bb", -2)}
+	for _, l := range []*T{New(), NewDescending()} {
+		for _, i := range rand.Perm(len(keys)) {
+			l.Insert(keys[i], i)
+		}
+		if err := valid(l); nil != err {
+			t.Error(err)
+		}
+		for i, k := range keys {
+			if l.Get(Tuple(k...)) != i {
+				t.Error(l, k)
+			}
+		}
+	}
+	if s := New().Insert(Tuple("a", 1), 1).Insert(Tuple("a", 0), 0).String(); s != "{[a 0]:0 [a 1]:1}" {
+		t.Error(s)
+	}
+}
+
+func TestT_FoldCase(t *testing.T) {
+	t.Parallel()
+	keys := []string{"", "a", "A", "a\x00", "Ab", "aB", "b", "README", "readme.md", "ÄBC", "äbd", "z"}
+	for _, l := range []*T{New().FoldCase(), NewDescending().FoldCase()} {
+		for _, i := range rand.Perm(len(keys)) {
+			l.Insert(keys[i], i)
+		}
+		if err := valid(l); nil != err || l.Len() != len(keys) {
+			t.Error(l, err)
+		}
+		for i, k := range keys {
+			if l.Get(k) != i {
+				t.Error(l, k)
+			}
+		}
+	}
+	if s := New().FoldCase().Set("b", 0).Set("C", 0).Set("a", 0).String(); s != "{a:0 b:0 C:0}" {
+		t.Error(s)
+	}
+}
+
+func TestT_NaturalOrder(t *testing.T) {
+	t.Parallel()
+	keys := []string{"", "file", "file!", "file01", "file1", "file2", "file2a", "file2b3", "file2b10", "file10", "file010x", "file9999999999999999999999", "file:", "filez"}
+	for _, l := range []*T{New().NaturalOrder(), NewDescending().NaturalOrder()} {
+		for _, i := range rand.Perm(len(keys)) {
+			l.Insert(keys[i], i)
+		}
+		if err := valid(l); nil != err {
+			t.Error(err)
+		}
+		for i, k := range keys {
+			want := i
+			if l.reversed {
+				want = len(keys) - 1 - i
+			}
+			if l.Get(k) != i || l.Pos(k) != want {
+				t.Error(l, k)
+			}
+		}
+	}
+}
+
+func TestT_ReflectKeys(t *testing.T) {
+	t.Parallel()
+	type inner struct {
+		ok bool
+		id [2]uint8
+	}
+	type key struct {
+		tenant string
+		at     int64
+		in     inner
+		weight float32
+	}
+	keys := []key{
+		{"a", -1, inner{true, [2]uint8{9, 9}}, 0},
+		{"a", 5, inner{false, [2]uint8{1, 2}}, 0},
+		{"a", 5, inner{false, [2]uint8{2, 1}}, -1},
+		{"a", 5, inner{false, [2]uint8{2, 1}}, 1},
+		{"a", 5, inner{true, [2]uint8{0, 0}}, 0},
+		{"b", -9, inner{}, 0},
+	}
+	for _, l := range []*T{New().ReflectKeys(), NewDescending().ReflectKeys()} {
+		for _, i := range rand.Perm(len(keys)) {
+			l.Insert(keys[i], i)
+		}
+		if err := valid(l); nil != err {
+			t.Error(err)
+		}
+		for i, k := range keys {
+			want := i
+			if l.reversed {
+				want = len(keys) - 1 - i
+			}
+			if l.Get(k) != i || l.Pos(k) != want {
+				t.Error(l, k)
+			}
+		}
+	}
+	if l := New().ReflectKeys().Insert(3, 3).Insert(1, 1); l.String() != "{1:1 3:3}" {
+		t.Error(l)
+	}
+	defer func() {
+		if r := recover(); r != "skiplist: struct { f func() } has fields that cannot be ordered" {
+			t.Error(r)
+		}
+	}()
+	New().ReflectKeys().Insert(struct{ f func() }{}, 0)
+}
+
+func TestT_BySequence(t *testing.T) {
+	t.Parallel()
+	l := New()
+	for _, k := range []int{5, 1, 3} {
+		l.Insert(k, k)
+	}
+	l.Insert(3, 33)
+	m := NewFromSorted([]interface{}{2, 4}, []interface{}{2, 4})
+	l.Merge(m)
+	l.ChangeKey(l.Element(5), 0)
+	var got []interface{}
+	for _, v := range l.BySequence() {
+		got = append(got, v)
+	}
+	if fmt.Sprint(got) != "[5 1 3 33 2 4]" {
+		t.Error(got)
+	}
+	if !l.InsertedBefore(l.Element(1), l.Element(2)) || l.InsertedBefore(l.Element(4), l.Element(0)) {
+		t.Error(l)
+	}
+	c := l.Clone().Insert(9, 9)
+	if !c.InsertedBefore(c.Element(4), c.Element(9)) || c.InsertedBefore(c.Element(9), c.Element(0)) {
+		t.Error(c)
+	}
+}
+
+func TestT_PosCeiling(t *testing.T) {
+	t.Parallel()
+	l := New()
+	if l.PosCeiling(1) != 0 || l.PosFloor(1) != -1 {
+		t.Error("empty list")
+	}
+	for i := 0; i < 20; i += 2 {
+		l.Insert(i, i).Insert(i, i)
+	}
+	for key, want := range map[int][2]int{-1: {0, -1}, 0: {0, 1}, 1: {2, 1}, 4: {4, 5}, 18: {18, 19}, 19: {20, 19}} {
+		if c, f := l.PosCeiling(key), l.PosFloor(key); c != want[0] || f != want[1] {
+			t.Error(key, c, f)
+		}
+	}
+}
+
+func TestT_RangeByScore(t *testing.T) {
+	t.Parallel()
+	l := New()
+	for _, i := range rand.Perm(10) {
+		l.Insert(&FastType{i, i}, i)
+	}
+	for _, c := range []struct {
+		min, max      float64
+		offset, limit int
+		want          string
+	}{
+		{4, 10, 0, -1, "[2 3 4 5]"},
+		{3.5, 9.5, 1, 2, "[3 4]"},
+		{-1, 100, 8, -1, "[8 9]"},
+		{-1, 100, 10, -1, "[]"},
+		{7, 6, 0, -1, "[]"},
+		{0, 18, 0, 0, "[]"},
+	} {
+		var got []interface{}
+		for _, e := range l.RangeByScore(c.min, c.max, c.offset, c.limit) {
+			got = append(got, e.Value)
+		}
+		if fmt.Sprint(got) != c.want {
+			t.Error(c, got)
+		}
+	}
+
+	// Descending lists return the same scores from max down to min.
+
+	d := NewDescending()
+	for _, i := range rand.Perm(10) {
+		d.Insert(&FastType{i, i}, i)
+	}
+	var got []interface{}
+	for _, e := range d.RangeByScore(4, 10, 1, -1) {
+		got = append(got, e.Value)
+	}
+	if fmt.Sprint(got) != "[4 3 2]" {
+		t.Error(got)
+	}
+	defer func() {
+		if nil == recover() {
+			t.Error("RangeByScore without scores did not panic")
+		}
+	}()
+	New().WithoutScores().Insert(1, 1).RangeByScore(0, 2, 0, -1)
+}
+
+func TestT_RangeByLex(t *testing.T) {
+	t.Parallel()
+	keys := []string{"a", "b", "foo", "fooa", "foo\xff", "foo\xff\x01", "fop", "z"}
+	s, b := New(), New()
+	for _, i := range rand.Perm(len(keys)) {
+		s.Insert(keys[i], i)
+		b.Insert([]byte(keys[i]), i)
+	}
+	for _, c := range []struct {
+		min, max string
+		want     string
+	}{
+		{"-", "+", "[0 1 2 3 4 5 6 7]"},
+		{"[b", "(fop", "[1 2 3 4 5]"},
+		{"(b", "[fop", "[2 3 4 5 6]"},
+		{"(a", "(b", "[]"},
+		{"[c", "[d", "[]"},
+		{"[z", "(a", "[]"},
+	} {
+		min, minIncl, _ := ParseLexBound(c.min)
+		max, maxIncl, _ := ParseLexBound(c.max)
+		for _, l := range []*T{s, b} {
+			var got []interface{}
+			for _, e := range l.RangeByLex(min, max, minIncl, maxIncl) {
+				got = append(got, e.Value)
+			}
+			if fmt.Sprint(got) != c.want {
+				t.Error(c, got)
+			}
+		}
+	}
+	if n := len(s.RangeByLex([]byte("foo"), PrefixEnd([]byte("foo")), true, false)); n != 4 {
+		t.Error(n)
+	}
+	if nil != PrefixEnd([]byte{0xff}) || string(PrefixEnd([]byte("a\xff"))) != "b" {
+		t.Error("PrefixEnd")
+	}
+	if _, _, err := ParseLexBound("x"); nil == err {
+		t.Error("parsed x")
+	}
+	defer func() {
+		if r := recover(); r != "skiplist: RangeByLex requires a list ordering keys bytewise" {
+			t.Error(r)
+		}
+	}()
+	New().FoldCase().Insert("Foo", 1).RangeByLex([]byte("f"), nil, true, false)
+}
+
+func TestT_WithKeyIndex(t *testing.T) {
+	t.Parallel()
+	l := New().Insert(3, -3).WithKeyIndex()
+	check := func(op string) {
+		for k := -1; k < 12; k++ {
+			e, _ := l.ElementPos(k)
+			if v, ok := l.GetOk(k); l.Element(k) != e || ok != (nil != e) || ok && v != e.Value {
+				t.Error(op, k, l)
+			}
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		k := rng.Intn(10)
+		switch rng.Intn(7) {
+		case 0, 1:
+			l.Insert(k, i)
+		case 2:
+			l.Set(k, i)
+		case 3:
+			l.Remove(k)
+		case 4:
+			if e := l.Element(k); nil != e {
+				l.InsertAfter(e, k, i)
+			}
+		case 5:
+			if e := l.Element(k); nil != e {
+				l.ChangeKey(e, rng.Intn(10))
+			}
+		case 6:
+			if n := l.Len(); n > 2 {
+				lo := rng.Intn(n - 1)
+				seg := l.SpliceOut(lo, lo+2)
+				check("SpliceOut")
+				l.SpliceIn(seg)
+			}
+		}
+		check("op")
+	}
+	l.Merge(New().Insert(4, "m").Insert(11, "m"))
+	check("Merge")
+	if l.Get(11) != "m" || l.Get(4) != "m" {
+		t.Error(l)
+	}
+	if c := l.Clone(); c.Element(4) == l.Element(4) || c.Get(4) != "m" {
+		t.Error(c)
+	}
+	if l.Clear(); nil != l.Element(4) {
+		t.Error(l)
+	}
+	if b := New().WithKeyIndex().Insert([]byte("a"), 1); b.Get([]byte("a")) != 1 {
+		t.Error(b)
+	}
+	// Keys ordered as equal but not equal values are still found.
+	if b := New().Insert(big.NewInt(5), "five").WithKeyIndex(); b.Get(big.NewInt(5)) != "five" {
+		t.Error(b)
+	}
+}
+
+func TestT_WithBloomFilter(t *testing.T) {
+	t.Parallel()
+	hash := func(key interface{}) uint64 { return uint64(key.(int)) * 0x9e3779b97f4a7c15 }
+	l := New().Insert(1, 1).WithBloomFilter(100, hash)
+	for i := 2; i < 100; i += 2 {
+		l.Insert(i, i).Insert(i, i)
+	}
+	l.Remove(1)
+	for i := 10; i < 20; i += 2 {
+		l.Remove(i)
+		l.Remove(i)
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := l.GetOk(i); ok != (0 == i%2 && (i < 10 || 20 <= i) && 0 != i) || ok && v != i {
+			t.Error(i, v, ok)
+		}
+	}
+	misses := 0
+	for i := 1000; i < 2000; i++ {
+		if !l.bloom.mayContain(i) {
+			misses++
+		}
+	}
+	if misses < 900 {
+		t.Error(misses)
+	}
+	if c := l.Clone(); c.Get(2) != 2 || nil != c.Element(3) {
+		t.Error(c)
+	}
+	if l.Clear(); l.bloom.mayContain(2) {
+		t.Error("cleared filter")
+	}
+}
+
+func TestT_WithFinger(t *testing.T) {
+	t.Parallel()
+	var compares int
+	less := func(a, b interface{}) bool { compares++; return a.(int) < b.(int) }
+	plain, fingered := NewFunc(less), NewFunc(less).WithFinger()
+	counts := [2]int{}
+	for i, l := range []*T{plain, fingered} {
+		compares = 0
+		for k := 0; k < 4096; k++ {
+			l.Insert(k, k)
+		}
+		for k := 0; k < 4096; k++ {
+			if l.Get(k) != k {
+				t.Error(k)
+			}
+		}
+		counts[i] = compares
+	}
+	if 2*counts[1] > counts[0] {
+		t.Error(counts)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		k := 4000 + rng.Intn(200)
+		for _, l := range []*T{plain, fingered} {
+			switch rng := rand.New(rand.NewSource(int64(i))); rng.Intn(5) {
+			case 0:
+				l.Insert(k, k)
+			case 1:
+				l.Remove(k)
+			case 2:
+				l.Set(k, k)
+			case 3:
+				if e := l.Element(k); nil != e {
+					l.InsertBefore(e, k, k)
+				}
+			case 4:
+				lo := l.Len() / 2
+				l.SpliceIn(l.SpliceOut(lo, lo+rng.Intn(l.Len()-lo+1)))
+			}
+		}
+		_, want := plain.ElementPos(k)
+		if e, pos := fingered.ElementPos(k); pos != want || nil != e && fingered.ElementN(pos) != e {
+			t.Error(k, pos, want)
+		}
+	}
+	if err := valid(fingered); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestT_WithAggregate(t *testing.T) {
+	t.Parallel()
+	concat := func(a, b interface{}) interface{} { return a.(string) + b.(string) }
+	l := New().WithAggregate("", concat)
+	brute := func(lo, hi int) string {
+		s := ""
+		for e := l.Front(); nil != e; e = e.Next() {
+			if k := e.Key().(int); lo <= k && k < hi {
+				s += e.Value.(string)
+			}
+		}
+		return s
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		k := rng.Intn(100)
+		v := string(rune('a' + rng.Intn(26)))
+		switch rng.Intn(8) {
+		case 0, 1:
+			l.Insert(k, v)
+		case 2:
+			l.Remove(k)
+		case 3:
+			l.Set(k, v)
+		case 4:
+			l.Upsert(k, func(old interface{}, exists bool) interface{} { return v })
+		case 5:
+			if e := l.Element(k); nil != e {
+				l.ChangeKey(e, rng.Intn(100))
+			}
+		case 6:
+			lo := rng.Intn(l.Len() + 1)
+			l.SpliceIn(l.SpliceOut(lo, lo+rng.Intn(l.Len()-lo+1)))
+		case 7:
+			if 0 == rng.Intn(10) {
+				l = l.Clone()
+			}
+		}
+		lo, hi := rng.Intn(110)-5, rng.Intn(110)-5
+		if got, want := l.AggregateRange(lo, hi), brute(lo, hi); got != want {
+			t.Fatal(i, lo, hi, got, want)
+		}
+	}
+	l.Merge(New().Set(50, "x").Set(150, "y"))
+	if got, want := l.AggregateRange(0, 200), brute(0, 200); got != want {
+		t.Error(got, want)
+	}
+	defer func() {
+		if nil == recover() {
+			t.Error("AggregateRange without WithAggregate did not panic")
+		}
+	}()
+	New().AggregateRange(0, 1)
+}
+
+func TestT_WithMonoid(t *testing.T) {
+	t.Parallel()
+	// Summarize values as {count, sum, max}.
+	type summary [3]int
+	zero := summary{0, 0, math.MinInt}
+	lift := func(v interface{}) interface{} { return summary{1, v.(int), v.(int)} }
+	combine := func(a, b interface{}) interface{} {
+		x, y := a.(summary), b.(summary)
+		return summary{x[0] + y[0], x[1] + y[1], max(x[2], y[2])}
+	}
+	l := New().Set(-1, 7).WithMonoid(zero, lift, combine)
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 2000; i++ {
+		switch k := rng.Intn(50); rng.Intn(3) {
+		case 0:
+			l.Insert(k, rng.Intn(1000))
+		case 1:
+			l.Remove(k)
+		case 2:
+			l.Set(k, rng.Intn(1000))
+		}
+		if 0 == i%500 {
+			l = l.Clone()
+		}
+		lo, hi := rng.Intn(60)-5, rng.Intn(60)-5
+		want := zero
+		for e := l.Front(); nil != e; e = e.Next() {
+			if k := e.Key().(int); lo <= k && k < hi {
+				want = combine(want, lift(e.Value)).(summary)
+			}
+		}
+		if got := l.ReduceRange(lo, hi); got != want {
+			t.Fatal(i, lo, hi, got, want)
+		}
+	}
+	if got := l.ReduceRange(-1, 0); got != (summary{1, 7, 7}) {
+		t.Error(got)
+	}
+}
+
+func TestT_WithSums(t *testing.T) {
+	t.Parallel()
+	l := New().WithSums()
+	for i := 0; i < 100; i++ {
+		l.Insert(i, float64(i))
+	}
+	l.IncrBy(10, 0.5)
+	l.Remove(20)
+	if s := l.SumRange(10, 30); s != 370.5 {
+		t.Error(s)
+	}
+	if s := l.SumRange(200, 300) + l.SumRange(30, 10); s != 0 {
+		t.Error(s)
+	}
+	if s := l.SumRange(-1, 100); s != 4930.5 {
+		t.Error(s)
+	}
+	func() {
+		defer func() {
+			if nil == recover() {
+				t.Error("SumRange without WithSums did not panic")
+			}
+		}()
+		New().WithAggregate("", func(a, b interface{}) interface{} { return a }).SumRange(0, 1)
+	}()
+	for _, bad := range []func(){
+		func() { l.Insert(1000, 1) },
+		func() { l.Set(10, 1) },
+		func() { l.InsertUnique(1000, 1) },
+		func() { l.CompareAndSwap(10, 10.5, 1) },
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Error("non-float64 value did not panic")
+				}
+			}()
+			bad()
+		}()
+		// The list is left unchanged.
+		if s := l.SumRange(-1, 100); s != 4930.5 || l.Len() != 99 || l.Get(10) != 10.5 {
+			t.Error(s, l.Len(), l.Get(10))
+		}
+	}
+}
+
+func TestT_Quantile(t *testing.T) {
+	t.Parallel()
+	l := New()
+	if nil != l.Median() || 0 != l.PercentRank(1) {
+		t.Error("empty list")
+	}
+	for _, i := range rand.Perm(100) {
+		l.Insert(i+1, nil)
+	}
+	for q, want := range map[float64]int{0: 1, 0.01: 1, 0.015: 2, 0.5: 50, 0.9: 90, 0.999: 100, 1: 100} {
+		if k := l.Quantile(q).Key(); k != want {
+			t.Error(q, k, want)
+		}
+	}
+	if k := l.Insert(101, nil).Median().Key(); k != 51 {
+		t.Error(k)
+	}
+	for key, want := range map[int]float64{0: 0, 1: 0, 51: 50.0 / 101, 102: 1} {
+		if r := l.PercentRank(key); r != want {
+			t.Error(key, r, want)
+		}
+	}
+	defer func() {
+		if nil == recover() {
+			t.Error("Quantile out of range did not panic")
+		}
+	}()
+	l.Quantile(math.NaN())
+}
+
+func TestT_Sample(t *testing.T) {
+	t.Parallel()
+	l := New()
+	if nil != l.RandomElement(nil) || nil != l.Sample(nil, 3) {
+		t.Error("empty list")
+	}
+	for i := 0; i < 10; i++ {
+		l.Insert(i, nil)
+	}
+	counts := make([]int, 10)
+	for i := 0; i < 10000; i++ {
+		counts[l.RandomElement(nil).Key().(int)]++
+	}
+	for i, c := range counts {
+		if c < 800 || c > 1200 {
+			t.Error(i, c)
+		}
+	}
+	counts = make([]int, 10)
+	for i := 0; i < 10000; i++ {
+		sample := l.Sample(nil, 3)
+		for j, e := range sample {
+			if 0 < j && e.Key().(int) <= sample[j-1].Key().(int) {
+				t.Fatal("sample out of order or repeated", sample)
+			}
+			counts[e.Key().(int)]++
+		}
+	}
+	for i, c := range counts {
+		if c < 2600 || c > 3400 {
+			t.Error(i, c)
+		}
+	}
+	if len(l.Sample(nil, 20)) != 10 || nil != l.Sample(nil, 0) {
+		t.Error("sample size")
+	}
+
+	// Samples are drawn correctly after removals, which leave stale
+	// levels in towers.
+
+	for i := 10; i < 1000; i++ {
+		l.Insert(i, nil)
+	}
+	for e := l.Front(); nil != e && l.Len() > 40; {
+		next := e.Next()
+		if len(e.links) < 5 {
+			l.RemoveElement(e)
+		}
+		e = next
+	}
+	want := l.Keys()
+	rng := randv2.New(randv2.NewPCG(1, 1))
+	for i := 0; i < 100; i++ {
+		sample := l.Sample(rng, 5)
+		for j, e := range sample {
+			if 0 < j && e.Key().(int) <= sample[j-1].Key().(int) || e != l.Element(e.Key()) {
+				t.Fatal("sample out of order or not in the list", sample, want)
+			}
+		}
+	}
+
+	// Sampling without an rng does not change the levels of later entries.
+
+	a, b := New(), New()
+	a.RandomElement(nil)
+	a.Insert(0, nil).Sample(nil, 1)
+	b.Insert(0, nil)
+	for i := 1; i < 64; i++ {
+		if x, y := a.InsertElem(i, nil), b.InsertElem(i, nil); len(x.links) != len(y.links) {
+			t.Fatal("levels disturbed by sampling", i)
+		}
+	}
+}
+
+func TestT_TopN(t *testing.T) {
+	t.Parallel()
+	l := NewDescending()
+	if nil != l.TopN(3) || nil != l.BottomN(3) {
+		t.Error("empty list")
+	}
+	for _, i := range rand.Perm(10) {
+		l.Insert(i, nil)
+	}
+	keys := func(elems []*Element) (s string) {
+		for _, e := range elems {
+			s += fmt.Sprint(e.Key())
+		}
+		return s
+	}
+	if s := keys(l.TopN(3)); s != "987" {
+		t.Error(s)
+	}
+	if s := keys(l.BottomN(3)); s != "210" {
+		t.Error(s)
+	}
+	if s := keys(l.TopN(20)) + keys(l.BottomN(-1)); s != "9876543210" {
+		t.Error(s)
+	}
+	l.WithMaxLen(4)
+	if s := fmt.Sprint(l); s != "{9:<nil> 8:<nil> 7:<nil> 6:<nil>}" {
+		t.Error(s)
+	}
+	l.Insert(2, nil).Insert(20, nil).Set(7, 1)
+	if s := keys(l.TopN(4)); s != "20987" || l.Len() != 4 {
+		t.Error(s, l.Len())
+	}
+	c := l.Clone()
+	c.Merge(NewDescending().Insert(15, nil))
+	if s := keys(c.TopN(9)); s != "201598" {
+		t.Error(s)
+	}
+	if s := keys(l.WithMaxLen(0).Insert(1, nil).TopN(9)); s != "209871" {
+		t.Error(s)
+	}
+
+	// Entries evicted at once are not returned.
+
+	l.WithMaxLen(3)
+	if nil != l.InsertElem(0, nil) || nil != l.Upsert(0, func(interface{}, bool) interface{} { return nil }) || l.InsertUnique(0, nil) {
+		t.Error("evicted element returned")
+	}
+	if nu, _ := l.SetElem(0, nil); nil != nu || nil != l.InsertAfter(l.ElementN(2), 0, nil) {
+		t.Error("evicted element returned")
+	}
+	if v, loaded := l.GetOrInsert(0, "v"); v != "v" || loaded {
+		t.Error(v, loaded)
+	}
+	if e := l.InsertElem(30, nil); nil == e || e != l.Front() || 3 != l.Len() {
+		t.Error(e, l)
+	}
+}
+
+func TestT_SearchRange(t *testing.T) {
+	t.Parallel()
+	maxInt := func(a, b interface{}) interface{} { return max(a.(int), b.(int)) }
+	l := New().WithAggregate(math.MinInt, maxInt)
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 1000; i++ {
+		l.Insert(rng.Intn(500), rng.Intn(1000))
+	}
+	for i := 0; i < 100; i++ {
+		lo, hi, min := rng.Intn(500), rng.Intn(500), rng.Intn(1000)
+		var want []*Element
+		for e := l.Front(); nil != e; e = e.Next() {
+			if k := e.Key().(int); lo <= k && k < hi && e.Value.(int) > min {
+				want = append(want, e)
+			}
+		}
+		got := l.SearchRange(lo, hi, func(s interface{}) bool { return s.(int) > min })
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatal(lo, hi, min, got, want)
+		}
+	}
+}
+
+func TestSeqWithWidths(t *testing.T) {
+	t.Parallel()
+	q := NewSeq().WithWidths(func(v interface{}) int { return len(v.(string)) })
+	if q.Width() != 0 {
+		t.Error(q.Width())
+	}
+	text := ""
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		chunk := strings.Repeat("x", rng.Intn(4))
+		switch index := rng.Intn(q.Len() + 1); rng.Intn(3) {
+		case 0, 1:
+			q.InsertN(index, chunk)
+		case 2:
+			if index < q.Len() {
+				q.SetN(index, chunk)
+			}
+		}
+		text = ""
+		for e := q.Front(); nil != e; e = e.Next() {
+			text += e.Value.(string)
+		}
+		if q.Width() != len(text) {
+			t.Fatal(i, q.Width(), len(text))
+		}
+	}
+	for offset := 0; offset <= len(text); offset++ {
+		index, start := q.IndexAt(offset)
+		if q.Offset(index) != start || start > offset {
+			t.Fatal(offset, index, start)
+		}
+		if index < q.Len() && offset >= start+len(q.GetN(index).(string)) {
+			t.Fatal(offset, index, start)
+		}
+		if index == q.Len() && start != len(text) {
+			t.Fatal(offset, index, start)
+		}
+	}
+	n, w := q.Len(), q.Width()
+	e := q.RemoveAt(w / 2)
+	if q.Len() != n-1 || q.Width() != w-len(e.Value.(string)) || nil != q.RemoveAt(w) {
+		t.Error(q.Len(), q.Width())
+	}
+}
+
+func TestT_ExpireAfter(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(0, 0)
+	l := New().WithClock(func() time.Time { return now })
+	for i := 0; i < 10; i++ {
+		l.SetWithTTL(i, i, time.Duration(i)*time.Second)
+	}
+	l.Set(10, 10).Set(3, 3)
+	l.ExpireAfter(l.Element(4), time.Hour)
+	now = now.Add(5 * time.Second)
+	if _, ok := l.GetOk(2); ok || l.Len() != 10 {
+		t.Error("expired entry found", l.Len())
+	}
+	if l.Get(3) != 3 || l.Get(4) != 4 || l.Get(6) != 6 {
+		t.Error("unexpired entry not found")
+	}
+	c := l.Clone()
+	if n := l.ExpireNow(); n != 3 || fmt.Sprint(l) != "{3:3 4:4 6:6 7:7 8:8 9:9 10:10}" {
+		t.Error(n, l)
+	}
+	l.Remove(7)
+	now = now.Add(time.Minute)
+	if n := l.ExpireNow(); n != 3 || l.Len() != 3 {
+		t.Error(n, l)
+	}
+	if n := c.ExpireNow(); n != 7 || c.Len() != 3 {
+		t.Error(n, c)
+	}
+	if n := l.Clear().ExpireNow(); n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestT_SetWithTTLEvicted(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(0, 0)
+	l := New().WithClock(func() time.Time { return now }).WithMaxLen(2).Set(1, 1).Set(2, 2)
+	l.SetWithTTL(3, 3, time.Second)
+	now = now.Add(time.Minute)
+	if n := l.ExpireNow(); n != 0 || fmt.Sprint(l) != "{1:1 2:2}" {
+		t.Error(n, l)
+	}
+}
+
+func TestT_RemoveRange(t *testing.T) {
+	t.Parallel()
+	l := New()
+	for i := 0; i < 10; i++ {
+		l.Insert(i, i).Insert(i, -i)
+	}
+	if n := l.RemoveRange(2, 5); n != 6 || l.Len() != 14 {
+		t.Error(n, l.Len())
+	}
+	if n := l.RemoveRange(8, 3) + l.RemoveRange(2, 5); n != 0 {
+		t.Error(n)
+	}
+	if n := l.RemoveRange(-10, 1); n != 2 || fmt.Sprint(l.Front()) != "1:-1" {
+		t.Error(n, l)
+	}
+}
+
+func TestT_Watch(t *testing.T) {
+	t.Parallel()
+	l := New()
+	ch, all := l.Watch(2, 5), l.Watch(math.MinInt, math.MaxInt)
+	for i := 0; i < 7; i++ {
+		l.Insert(i, float64(i))
+	}
+	l.Remove(3)
+	l.IncrBy(4, 0.5)
+	l.ChangeKey(l.Element(4), 6)
+	events := ""
+	for len(ch) > 0 {
+		ev := <-ch
+		events += fmt.Sprint(ev.Kind, ev.Key, ev.Value, " ")
+	}
+	if events != "0 2 2 0 3 3 0 4 4 1 3 3 2 4 4.5 1 4 4.5 " {
+		t.Error(events)
+	}
+	if !l.Unwatch(ch) || l.Unwatch(ch) {
+		t.Error("Unwatch")
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel open after Unwatch")
+	}
+	for i := 0; len(all) < cap(all); i++ {
+		l.Insert(i, nil)
+	}
+	l.Insert(0, nil)
+	for range all {
+	}
+	if l.Unwatch(all) {
+		t.Error("watch that fell behind still running")
+	}
+}
+
+func TestT_OnInsert(t *testing.T) {
+	t.Parallel()
+	index := map[*Element]bool{}
+	replaced := 0
+	l := New().WithMaxLen(8).
+		OnInsert(func(e *Element) { index[e] = true }).
+		OnRemove(func(e *Element) { delete(index, e) }).
+		OnReplace(func(old, nu *Element) { replaced++ })
+	check := func(what string) {
+		if len(index) != l.Len() {
+			t.Fatal(what, len(index), l.Len())
+		}
+		for e := l.Front(); nil != e; e = e.Next() {
+			if !index[e] {
+				t.Fatal(what, e)
+			}
+		}
+	}
+	for i := 0; i < 10; i++ {
+		l.Insert(i, i)
+	}
+	check("Insert")
+	l.Set(3, 30).Set(4, 40).Remove(5)
+	check("Set")
+	l.ChangeKey(l.Element(6), 100)
+	check("ChangeKey")
+	l.SpliceIn(l.SpliceOut(1, 4))
+	l.RemoveRange(0, 2)
+	check("Splice")
+	l.Merge(New().Insert(50, nil))
+	check("Merge")
+	if replaced != 2 {
+		t.Error(replaced)
+	}
+	l.Clear()
+	check("Clear")
+}
+
+func TestT_WithOpLog(t *testing.T) {
+	t.Parallel()
+	l := New().WithMaxLen(8).WithOpLog()
+	var replica []Op
+	var seq uint64
+	check := func(what string) {
+		for _, op := range l.OpsSince(seq) {
+			if op.Seq != seq+1 {
+				t.Fatal(what, op.Seq, seq)
+			}
+			switch seq = op.Seq; op.Kind {
+			case Inserted:
+				replica = append(replica[:op.Pos], append([]Op{op}, replica[op.Pos:]...)...)
+			case Removed:
+				replica = append(replica[:op.Pos], replica[op.Pos+1:]...)
+			case Updated:
+				replica[op.Pos] = op
+			}
+		}
+		if len(replica) != l.Len() {
+			t.Fatal(what, len(replica), l.Len())
+		}
+		for i, e := 0, l.Front(); nil != e; i, e = i+1, e.Next() {
+			if replica[i].Key != e.Key() || replica[i].Value != e.Value {
+				t.Fatal(what, i, replica[i], e)
+			}
+		}
+	}
+	for _, i := range rand.Perm(10) {
+		l.Insert(i, i)
+	}
+	check("Insert")
+	l.Set(3, 30).Set(4, 40).Remove(5)
+	l.Upsert(6, func(old interface{}, exists bool) interface{} { return 60 })
+	check("Set")
+	l.ChangeKey(l.Element(6), 100)
+	check("ChangeKey")
+	l.SpliceIn(l.SpliceOut(1, 4))
+	l.RemoveRange(0, 2)
+	check("Splice")
+	l.Merge(New().Insert(50, 5).Insert(-1, 1))
+	check("Merge")
+	l.TrimOps(seq - 1)
+	if ops := l.OpsSince(seq - 1); len(ops) != 1 || ops[0].Seq != seq {
+		t.Error(ops)
+	}
+	l.Clear()
+	check("Clear")
+	l.TrimOps(seq + 10)
+	if nil != l.OpsSince(seq) {
+		t.Error(l.OpsSince(seq))
+	}
+	defer func() {
+		if r := recover(); nil == r {
+			t.Error("OpsSince of trimmed ops did not panic")
+		}
+	}()
+	l.OpsSince(0)
+}
+
+func TestT_MergeHooks(t *testing.T) {
+	t.Parallel()
+	l := New()
+	for i := 0; i < 10; i += 2 {
+		l.Insert(i, i)
+	}
+	var inserted []interface{}
+	l.OnInsert(func(e *Element) {
+		// Merge completes the list before reporting insertions.
+		if l.Element(e.Key()) != e {
+			t.Error("incomplete list at insertion of", e)
+		}
+		inserted = append(inserted, e.Key())
+	})
+	var removed []interface{}
+	other := New().Insert(1, 1).Insert(3, 3).
+		OnRemove(func(e *Element) { removed = append(removed, e.Key()) })
+	l.Merge(other)
+	if s := fmt.Sprint(removed, inserted); s != "[1 3] [1 3]" {
+		t.Error(s)
+	}
+	if 0 != other.Len() || nil != other.Front() || 7 != l.Len() {
+		t.Error(other, l)
+	}
+}
+
+func TestT_MergeOpLog(t *testing.T) {
+	t.Parallel()
+	l := New().WithOpLog()
+	for i := 0; i < 10; i += 2 {
+		l.Insert(i, i)
+	}
+	l.TrimOps(5)
+	removed := 0
+	other := New().Insert(1, 1).Insert(3, 3).WithOpLog().
+		OnRemove(func(e *Element) { removed++ })
+	l.Merge(other)
+	if s := fmt.Sprint(other.OpsSince(0)); s != "[{1 1 0 1 1} {3 1 0 2 3}]" {
+		t.Error(s)
+	}
+	if s := fmt.Sprint(l.OpsSince(5)); s != "[{1 0 1 6 1} {3 0 3 7 3}]" {
+		t.Error(s)
+	}
+	if 2 != removed {
+		t.Error(removed)
+	}
+}
+
+func TestT_WithNaNKeys(t *testing.T) {
+	t.Parallel()
+	nan := math.NaN()
+	for _, c := range []struct {
+		l    *T
+		want string
+	}{
+		{New().WithNaNKeys(NaNFirst), "{NaN:b 1:1 2:2 3:3}"},
+		{New().WithNaNKeys(NaNLast), "{1:1 2:2 3:3 NaN:b}"},
+		{NewDescending().WithNaNKeys(NaNLast), "{3:3 2:2 1:1 NaN:b}"},
+	} {
+		c.l.Set(2.0, 2).Set(nan, "a").Set(3.0, 3).Set(nan, "b").Set(1.0, 1)
+		if err := valid(c.l); nil != err || c.l.String() != c.want || c.l.Get(nan) != "b" {
+			t.Error(c.l, err)
+		}
+	}
+	r := New().WithNaNKeys(NaNRejected).Insert(1.0, 1)
+	if err := r.TryInsert(nan, 0); err != ErrNaNKey || r.Len() != 1 {
+		t.Error(err, r)
+	}
+	func() {
+		defer func() {
+			if x := recover(); x != "skiplist: NaN key rejected" {
+				t.Error(x)
+			}
+		}()
+		r.Insert(float32(nan), 0)
+	}()
+}
+
+func TestWideIntegerKeys(t *testing.T) {
+	t.Parallel()
+	for _, l := range []*T{New(), NewDescending()} {
+		for _, i := range rand.Perm(100) {
+			l.Insert(math.MaxUint64-uint64(i), i)
+		}
+		if err := valid(l); nil != err || l.Len() != 100 {
+			t.Error(err)
+		}
+		for i := 0; i < 100; i++ {
+			if l.Get(math.MaxUint64-uint64(i)) != i {
+				t.Error(i)
+			}
+		}
+	}
+	l := New()
+	for _, i := range rand.Perm(100) {
+		l.Insert(int64(1<<62+i), i)
+	}
+	for i, e := 0, l.Front(); nil != e; i, e = i+1, e.Next() {
+		if e.Value != i {
+			t.Error(i, e)
+		}
+	}
+}
+
+func TestT_InsertElem(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4)
+	if e := l.InsertElem(2, "dup"); e.Key() != 2 || e.Value != "dup" || l.Element(2) != e {
+		t.Error(e)
+	}
+	nu, replaced := l.SetElem(2, "set")
+	if nu.Value != "set" || l.Element(2) != nu || replaced.Value != "dup" {
+		t.Error(nu, replaced)
+	}
+	if nu, replaced = l.SetElem(7, 14); nil != replaced || l.Element(7) != nu {
+		t.Error(nu, replaced)
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 1:2 2:set 2:4 3:6 4:8 7:14}" {
+		t.Error(l, err)
+	}
+}
+
+func TestT_InsertAfter(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4).Insert(2, "b")
+	mark := l.Element(2)
+	l.InsertAfter(mark, 2, "c")
+	l.InsertBefore(mark, 2, "a")
+	l.InsertAfter(mark, 9, "wrong hint")
+	l.InsertBefore(&Element{key: 1, links: make([]link, 1)}, 1, "foreign")
+	if e := l.InsertAfter(l.Element(4), 5, 10); e.Key() != 5 || l.Element(5) != e {
+		t.Error(e)
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 1:foreign 1:2 2:a 2:b 2:c 2:4 3:6 4:8 5:10 9:wrong hint}" {
+		t.Error(l, err)
+	}
+
+	// Valid hints place entries beside their marks, as the list grows.
+
+	l = New().Insert(0, nil)
+	for i := 0; i < 300; i++ {
+		mark := l.ElementN(rand.Intn(l.Len()))
+		k := mark.Key().(int) + rand.Intn(3) - 1
+		var e *Element
+		if rand.Intn(2) == 0 {
+			if e = l.InsertAfter(mark, k, i); k == mark.Key() && mark.Next() != e {
+				t.Fatal("not after mark", i)
+			}
+		} else if e = l.InsertBefore(mark, k, i); k == mark.Key() && e.Next() != mark {
+			t.Fatal("not before mark", i)
+		}
+		if err := valid(l); nil != err {
+			t.Fatal(i, err)
+		}
+	}
+}
+
+func TestT_InsertUnique(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4)
+	if l.InsertUnique(3, "dup") || !l.InsertUnique(5, 10) || l.InsertUnique(5, "dup") {
+		t.Fail()
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 1:2 2:4 3:6 4:8 5:10}" {
+		t.Error(l, err)
+	}
+}
+
+func TestT_Upsert(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4).Insert(2, "young")
+	count := func(old interface{}, exists bool) interface{} {
+		if !exists {
+			return 1
+		}
+		return fmt.Sprint(old, "+1")
+	}
+	l.Upsert(2, count)
+	if e := l.Upsert(7, count); e.Value != 1 || e.Key() != 7 {
+		t.Error(e)
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 1:2 2:young+1 2:4 3:6 4:8 7:1}" {
+		t.Error(l, err)
+	}
+	if e := New().Upsert("a", count); e.Value != 1 {
+		t.Error(e)
+	}
+}
+
+func TestT_GetOrInsert(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4)
+	if v, loaded := l.GetOrInsert(3, "new"); !loaded || v != 6 {
+		t.Error(v, loaded)
+	}
+	if v, loaded := l.GetOrInsert(9, "new"); loaded || v != "new" {
+		t.Error(v, loaded)
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 1:2 2:4 3:6 4:8 9:new}" {
+		t.Error(l, err)
+	}
+}
+
+func TestNewWithCapacity(t *testing.T) {
+	t.Parallel()
+	l := NewWithCapacity(100)
+	if cap(l.links) != 7 {
+		t.Error(cap(l.links))
+	}
+	for i := 0; i < 1000; i++ {
+		l.Insert(i, i)
+	}
+	for e := l.Front(); nil != e; e = e.Next() {
+		if len(e.links) > 7 {
+			t.Fatal("level cap exceeded")
+		}
+	}
+	if err := valid(l); nil != err {
+		t.Error(err)
+	}
+
+	// Rebuilding the list, as Merge does, keeps the cap.
+
+	l = NewWithCapacity(4).Merge(skiplist(0, 999))
+	for e := l.Front(); nil != e; e = e.Next() {
+		if len(e.links) > 3 {
+			t.Fatal("level cap exceeded by Merge")
+		}
+	}
+	if err := valid(l); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestT_WithSizeHint(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 2).WithSizeHint(1000)
+	links := &l.links[0]
+	for i := 3; i < 1000; i++ {
+		l.Insert(i, i)
+	}
+	if &l.links[0] != links || cap(l.links) != 10 {
+		t.Error("level structures reallocated")
+	}
+	if err := valid(l); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestT_MoveN(t *testing.T) {
+	t.Parallel()
+	l := New()
+	for i := 0; i < 6; i++ {
+		l.Insert(i/3, i)
+	}
+	l.MoveN(0, 2).MoveN(5, 3).SwapN(0, 1)
+	if err := valid(l); nil != err || l.String() != "{0:0 0:1 0:2 1:3 1:5 1:4}" {
+		t.Error(l, err)
+	}
+	for _, bad := range []func(){
+		func() { l.MoveN(0, 3) },
+		func() { l.MoveN(4, 1) },
+		func() { l.MoveN(0, 6) },
+		func() { l.SwapN(2, 3) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			bad()
+		}()
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 0:1 0:2 1:3 1:5 1:4}" {
+		t.Error(l, err)
+	}
+}
+
+func TestSeq(t *testing.T) {
+	t.Parallel()
+	q := NewSeq()
+	for i := 0; i < 100; i++ {
+		q.InsertN(i/2, i)
+	}
+	q.SetN(0, "first").RemoveN(99)
+	if q.Len() != 99 || q.GetN(0) != "first" || q.GetN(49) != 99 || q.GetN(50) != 98 || q.GetN(98) != 2 {
+		t.Error(q.GetN(0), q.GetN(49), q.GetN(50), q.GetN(98))
+	}
+	if err := valid(q.l); nil != err {
+		t.Error(err)
+	}
+	if nil != q.ElementN(-1) || nil != q.ElementN(99) || nil != q.RemoveN(99) {
+		t.Error("out of range element")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	q.InsertN(100, nil)
+}
+
+func TestT_Walk(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9)
+	sum := 0
+	l.Walk(func(e *Element) bool {
+		sum += e.Key().(int)
+		return e.Key().(int) < 4
+	})
+	if sum != 10 {
+		t.Error(sum)
+	}
+	sum = 0
+	l.WalkRange(3, 7, func(e *Element) bool {
+		sum += e.Key().(int)
+		return true
+	})
+	if sum != 18 {
+		t.Error(sum)
+	}
+	New().Walk(func(e *Element) bool {
+		t.Error("walked empty list")
+		return true
+	})
+}
+
+func TestT_HeadN(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9)
+	head, tail := l.HeadN(3), l.TailN(4)
+	keys := func(v *View) (s string) {
+		v.All()(func(key, value interface{}) bool {
+			s += fmt.Sprint(key)
+			return true
+		})
+		return s
+	}
+	if keys(head) != "012" || keys(tail) != "6789" || head.Front().Key() != 0 || tail.ElementN(3).Key() != 9 {
+		t.Error(keys(head), keys(tail))
+	}
+	if nil != tail.ElementN(4) || nil != tail.ElementN(-1) {
+		t.Error("element outside view")
+	}
+	l.Remove(0)
+	l.Insert(10, 20)
+	if keys(head) != "123" || keys(tail) != "78910" {
+		t.Error(keys(head), keys(tail))
+	}
+	l.Truncate(2)
+	if head.Len() != 2 || tail.Len() != 2 || keys(l.HeadN(0)) != "" {
+		t.Error(head.Len(), tail.Len())
+	}
+}
+
+func TestT_Sub(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9)
+	sub := l.Sub(3, 6)
+	if sub.Len() != 3 || sub.Front().Key() != 3 || sub.ElementN(2).Key() != 5 || nil != sub.ElementN(3) {
+		t.Error(sub.Len())
+	}
+	if v, ok := sub.GetOk(4); !ok || v != 8 {
+		t.Error(v, ok)
+	}
+	if _, ok := sub.GetOk(6); ok || nil != sub.Element(2) {
+		t.Error("key outside view")
+	}
+	l.Remove(3)
+	l.Insert(5, "dup")
+	if sub.Len() != 3 || sub.Front().Key() != 4 || sub.ElementN(1).Value != "dup" {
+		t.Error(sub.Len())
+	}
+	if l.Sub(6, 3).Len() != 0 || l.Sub(20, 30).Len() != 0 {
+		t.Error("empty interval")
+	}
+}
+
+func TestT_Descending(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 5)
+	keys := func(v *View) (s string) {
+		v.All()(func(key, value interface{}) bool {
+			s += fmt.Sprint(key)
+			return true
+		})
+		return s
+	}
+	d := l.Descending()
+	if keys(d) != "543210" || d.Front().Key() != 5 || d.ElementN(5).Key() != 0 || nil != d.ElementN(6) {
+		t.Error(keys(d))
+	}
+	if keys(l.Sub(1, 4).Descending()) != "321" || keys(l.HeadN(2).Descending().Descending()) != "01" {
+		t.Error("composed views")
+	}
+	l.Insert(9, 18)
+	if keys(d) != "9543210" {
+		t.Error(keys(d))
+	}
+}
+
+func TestT_Truncate(t *testing.T) {
+	t.Parallel()
+	for _, n := range []int{0, 1, 7, 64, 99, 100, 200} {
+		l := skiplist(0, 99).TrackBelow(50).Truncate(n)
+		want := n
+		if want > 100 {
+			want = 100
+		}
+		if err := valid(l); nil != err || l.Len() != want || want > 0 && l.ElementN(want-1).Key() != want-1 {
+			t.Error(n, l, err)
+		}
+		if below := l.CountBelow(50); below != want && !(want > 50 && below == 50) {
+			t.Error(n, below)
+		}
+	}
+}
+
+func TestSortedSet(t *testing.T) {
+	t.Parallel()
+	s := NewSortedSet()
+	for _, k := range []string{"d", "b", "a", "c", "b"} {
+		s.Add(k)
+	}
+	if s.Len() != 4 || !s.Contains("c") || s.Contains("e") || s.Rank("c") != 2 || s.Rank("e") != -1 {
+		t.Error(s.l)
+	}
+	if k, ok := s.DeleteRank(1); !ok || k != "b" || s.Contains("b") || !s.Remove("d") || s.Remove("d") {
+		t.Error(s.l)
+	}
+	if k, ok := s.KeyN(1); !ok || k != "c" {
+		t.Error(k, ok)
+	}
+	if _, ok := s.KeyN(2); ok {
+		t.Error("key past end")
+	}
+	if _, ok := s.DeleteRank(-1); ok {
+		t.Error("deleted negative rank")
+	}
+}
+
+func TestNewByKeyFunc(t *testing.T) {
+	t.Parallel()
+	type user struct {
+		name string
+		age  int
+	}
+	k := NewByKeyFunc(func(v interface{}) interface{} { return v.(*user).name })
+	k.Insert(&user{"carol", 30}).Insert(&user{"alice", 40}).Insert(&user{"bob", 20}).Set(&user{"bob", 21})
+	if v, ok := k.Get("bob"); !ok || v.(*user).age != 21 || k.Len() != 3 {
+		t.Error(v, ok)
+	}
+	if v, ok := k.Remove("alice"); !ok || v.(*user).age != 40 {
+		t.Error(v, ok)
+	}
+	if _, ok := k.Get("alice"); ok {
+		t.Error("removed value found")
+	}
+	names := ""
+	k.All()(func(v interface{}) bool {
+		names += v.(*user).name + " "
+		return true
+	})
+	if names != "bob carol " {
+		t.Error(names)
+	}
+
+	// Keys need only be accepted by New.
+
+	type host struct {
+		addr netip.Addr
+		id   *big.Int
+	}
+	byAddr := NewByKeyFunc(func(v interface{}) interface{} { return v.(host).addr })
+	byID := NewByKeyFunc(func(v interface{}) interface{} { return v.(host).id })
+	for i, a := range []string{"10.0.0.2", "::1", "10.0.0.10", "10.0.0.1"} {
+		h := host{netip.MustParseAddr(a), new(big.Int).Lsh(big.NewInt(int64(4-i)), 70)}
+		byAddr.Insert(h)
+		byID.Insert(h)
+	}
+	if v, ok := byAddr.Get(netip.MustParseAddr("10.0.0.10")); !ok || v.(host).addr.String() != "10.0.0.10" {
+		t.Error(v, ok)
+	}
+	addrs, ids := "", ""
+	byAddr.All()(func(v interface{}) bool { addrs += v.(host).addr.String() + " "; return true })
+	byID.All()(func(v interface{}) bool { ids += v.(host).addr.String() + " "; return true })
+	if addrs != "10.0.0.1 10.0.0.2 10.0.0.10 ::1 " || ids != "10.0.0.1 10.0.0.10 ::1 10.0.0.2 " {
+		t.Error(addrs, ids)
+	}
+}
+
+func TestT_DeleteCursor(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 99).Insert(40, "dup")
+	c := l.DeleteCursor(10, 90)
+	for e := c.Element(); nil != e; e = c.Element() {
+		if _, dup := e.Value.(string); dup || 0 == e.Key().(int)%3 {
+			c.Next()
+		} else if c.DeleteCurrent() != e {
+			t.Fatal("wrong element deleted")
+		}
+	}
+	if c.Next() != nil || c.DeleteCurrent() != nil {
+		t.Error("cursor ran past its range")
+	}
+	if err := valid(l); nil != err {
+		t.Error(err)
+	}
+	if l.Len() != 47 || l.Get(40) != "dup" || nil == l.Element(90) || nil != l.Element(89) {
+		t.Error(l)
+	}
+	for l.DeleteCursor(0, 100).DeleteCurrent() != nil {
+	}
+	if err := valid(l); nil != err || l.Len() != 0 {
+		t.Error(l, err)
+	}
+}
+
+func TestT_LoadAndDelete(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4)
+	if v, loaded := l.LoadAndDelete(3); !loaded || v != 6 {
+		t.Error(v, loaded)
+	}
+	if v, loaded := l.LoadAndDelete(3); loaded || v != nil {
+		t.Error(v, loaded)
+	}
+	if l.String() != "{0:0 1:2 2:4 4:8}" {
+		t.Error(l)
+	}
+}
+
+func TestT_IncrBy(t *testing.T) {
+	t.Parallel()
+	l := New()
+	if l.IncrBy("a", 2) != 2 || l.IncrBy("a", 0.5) != 2.5 || l.IncrBy("b", -1) != -1 {
+		t.Fail()
+	}
+	if l.String() != "{a:2.5 b:-1}" {
+		t.Error(l)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for non-float64 value")
+		}
+	}()
+	l.Set("c", 1).IncrBy("c", 1)
+}
+
+func TestT_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4)
+	if l.CompareAndSwap(3, 5, "no") || !l.CompareAndSwap(3, 6, "yes") || l.CompareAndSwap(7, nil, 1) {
+		t.Fail()
+	}
+	if l.String() != "{0:0 1:2 2:4 3:yes 4:8}" {
+		t.Error(l)
+	}
+}
+
+func TestT_CompareAndDelete(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4)
+	if l.CompareAndDelete(3, 5) || !l.CompareAndDelete(3, 6) || l.CompareAndDelete(3, 6) || l.CompareAndDelete(7, nil) {
+		t.Fail()
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 1:2 2:4 4:8}" {
+		t.Error(l, err)
+	}
+}
+
+func TestT_WithSeed(t *testing.T) {
+	t.Parallel()
+	levels := func(l *T) (s string) {
+		for i := 0; i < 64; i++ {
+			l.Insert(i, i)
+		}
+		for e := l.Front(); nil != e; e = e.Next() {
+			s += fmt.Sprint(len(e.links))
+		}
+		return s
+	}
+	a, b := levels(New().WithSeed(1)), levels(New().WithSeed(1))
+	if a != b || a == levels(New()) {
+		t.Error(a, b)
+	}
+	if c := levels(New().WithRand(randv2.New(randv2.NewPCG(1, 42)))); c != a {
+		t.Error(c, a)
+	}
+	if flat := levels(New().WithSource(odd{})); flat != strings.Repeat("1", 64) {
+		t.Error(flat)
+	}
+}
+
+// Type odd is a Source of odd numbers, which yield single-level elements.
+//
+type odd struct{}
+
+func (odd) Uint64() uint64 { return 1 }
+
+func TestT_WithProbability(t *testing.T) {
 	t.Parallel()
-	if skiplist(1, 10).String() != "{1:2 2:4 3:6 4:8 5:10 6:12 7:14 8:16 9:18 10:20}" {
-		t.Fail()
+	links := func(l *T) (n int) {
+		for i := 0; i < 4096; i++ {
+			l.Insert(i, i)
+		}
+		for e := l.Front(); nil != e; e = e.Next() {
+			n += len(e.links)
+		}
+		return n
+	}
+	quarter, half := New().WithProbability(0.25), New().WithProbability(0.5)
+	if q, h := links(quarter), links(half); q > 4096*3/2 || h < 4096*3/2 {
+		t.Error(q, h)
 	}
+	if err := valid(quarter); nil != err {
+		t.Error(err)
+	}
+
+	// Rebuilding the list, as Merge does, keeps the probability.
+
+	quarter = New().WithProbability(0.25).Merge(skiplist(0, 4095))
+	n := 0
+	for e := quarter.Front(); nil != e; e = e.Next() {
+		n += len(e.links)
+	}
+	if n > 4096*3/2 {
+		t.Error(n)
+	}
+	if err := valid(quarter); nil != err {
+		t.Error(err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	New().WithProbability(1)
 }
 
-func TestT_Get(t *testing.T) {
+func TestT_CheckOwner(t *testing.T) {
 	t.Parallel()
-	l := skiplist(0, 7)
-	if l.Get(0).(int) != 0 || l.Get(4).(int) != 8 || l.Get(7).(int) != 14 {
-		t.Fail()
+	l := New().CheckOwner().Insert(1, 1)
+	done := make(chan interface{})
+	for _, modify := range []func(){
+		func() { l.Insert(2, 2) },
+		func() { l.Remove(1) },
+		func() { l.Clear() },
+	} {
+		go func() {
+			defer func() { done <- recover() }()
+			modify()
+		}()
+		if r, _ := (<-done).(string); !strings.HasPrefix(r, "skiplist: modified by goroutine") {
+			t.Error(r)
+		}
+		n := 0
+		for e := l.Front(); nil != e; e = e.Next() {
+			n++
+		}
+		if err := valid(l); nil != err || 1 != n || 1 != l.Len() {
+			t.Error(n, l.Len(), err)
+		}
+	}
+	l.Remove(1)
+	go func() {
+		defer func() { done <- recover() }()
+		l.CheckOwner().Insert(3, 3)
+	}()
+	if r := <-done; nil != r {
+		t.Error(r)
 	}
 }
 
-func TestT_GetOk(t *testing.T) {
+func TestT_UpdateKey(t *testing.T) {
 	t.Parallel()
-	l := skiplist(1, 3)
-	v, ok := l.GetOk(0)
-	if nil != v || false != ok {
-		t.Fail()
+	l := skiplist(0, 9)
+	e := l.Element(3)
+	if l.UpdateKey(3, 42) != e || e.Key() != 42 || l.ElementN(9) != e {
+		t.Error(l)
 	}
-	v, ok = l.GetOk(1)
-	if 2 != v.(int) || true != ok {
-		t.Fail()
+	if nil != l.UpdateKey(3, 0) {
+		t.Error("moved absent key")
 	}
-	v, ok = l.GetOk(3)
-	if 6 != v.(int) || true != ok {
-		t.Fail()
+	for i := 0; i < 1000; i++ {
+		l.UpdateKey(l.Front().Key(), 100+i)
 	}
-	v, ok = l.GetOk(4)
-	if nil != v || false != ok {
-		t.Fail()
+	if err := valid(l); nil != err {
+		t.Error(err)
+	}
+	if l.Len() != 10 || l.ElementN(9).Key() != 1099 {
+		t.Error(l)
 	}
 }
 
-func TestT_GetAll(t *testing.T) {
+func TestNewFunc(t *testing.T) {
 	t.Parallel()
-	l := skiplist(1, 3).Insert(2, 3).Insert(2, 5)
-	a := l.GetAll(0)
-	if 0 != len(a) {
-		t.Fail()
+	l := NewFunc(func(a, b interface{}) bool { return strings.ToLower(a.(string)) < strings.ToLower(b.(string)) })
+	l.Insert("b", 1).Insert("C", 2).Insert("a", 3).Set("A", 4)
+	if err := valid(l); nil != err || l.String() != "{A:4 b:1 C:2}" || l.Get("c") != 2 {
+		t.Error(l, err)
 	}
-	a = l.GetAll(1)
-	if 1 != len(a) || 2 != a[0] {
-		t.Fail()
+
+	// Neither NewFunc nor NewByKeyFunc lists have scores to range over.
+
+	byName := NewByKeyFunc(func(v interface{}) interface{} { return v.(string) }).Insert("a")
+	for _, l := range []*T{l, byName.l} {
+		func() {
+			defer func() {
+				if r := recover(); r != "skiplist: RangeByScore called on a list without scores" {
+					t.Error(r)
+				}
+			}()
+			l.RangeByScore(0, 0, 0, -1)
+		}()
 	}
-	a = l.GetAll(2)
-	if 3 != len(a) || 5 != a[0] || 3 != a[1] || 4 != a[2] {
-		t.Fail()
+}
+
+func TestNewWithScore(t *testing.T) {
+	t.Parallel()
+	type point struct{ x, y int }
+	less := func(a, b interface{}) bool {
+		p, q := a.(point), b.(point)
+		return p.x < q.x || p.x == q.x && p.y < q.y
 	}
-	a = l.GetAll(3)
-	if 1 != len(a) || 6 != a[0] {
-		t.Fail()
+	l := NewWithScore(less, func(a interface{}) float64 { return float64(a.(point).x) })
+	l.Insert(point{2, 1}, "c").Insert(point{1, 5}, "b").Insert(point{2, 0}, "d").Insert(point{1, 2}, "a")
+	if err := valid(l); nil != err || l.String() != "{{1 2}:a {1 5}:b {2 0}:d {2 1}:c}" {
+		t.Error(l, err)
 	}
-	a = l.GetAll(4)
-	if 0 != len(a) {
-		t.Fail()
+	if n := len(l.RangeByScore(1, 1, 0, -1)); n != 2 {
+		t.Error(n)
 	}
 }
 
-func TestT_Set(t *testing.T) {
+func TestT_Filter(t *testing.T) {
 	t.Parallel()
-	l := skiplist(1, 3)
-	l.Set(2, 2)
-	a := l.GetAll(2)
-	if 1 != len(a) || a[0].(int) != 2 {
-		t.Fail()
+	l := NewDescending()
+	for i := 0; i < 10; i++ {
+		l.Insert(i, i*i)
+	}
+	odd := l.Filter(func(key, value interface{}) bool { return 1 == key.(int)%2 }).Insert(4, "new")
+	if err := valid(odd); nil != err || odd.String() != "{9:81 7:49 5:25 4:new 3:9 1:1}" || l.Len() != 10 {
+		t.Error(odd, err)
 	}
 }
 
-func TestT_Remove(t *testing.T) {
+func TestSetOperations(t *testing.T) {
 	t.Parallel()
-	s := skiplist(0, 10)
-	if s.Remove(-1) != nil || s.Remove(11) != nil {
-		t.Error("Removing nonexistant key should fail.")
+	a := skiplist(0, 5).Insert(3, "dup")
+	b := skiplist(3, 8)
+	for _, c := range []struct {
+		l    *T
+		want string
+	}{
+		{Union(a, b), "{0:0 1:2 2:4 3:dup 3:6 4:8 5:10 6:12 7:14 8:16}"},
+		{Intersect(a, b), "{3:dup 3:6 4:8 5:10}"},
+		{Difference(a, b), "{0:0 1:2 2:4}"},
+		{Difference(b, a), "{6:12 7:14 8:16}"},
+		{Union(New(), New()), "{}"},
+	} {
+		if err := valid(c.l); nil != err || c.l.String() != c.want {
+			t.Error(c.l, err)
+		}
 	}
-	for i := range rand.Perm(11) {
-		e := s.Remove(i)
-		if e == nil {
-			t.Error("nil")
+}
+
+func TestT_ChangeKey(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9).Insert(4, "dup")
+	e := l.Element(4).Next()
+	if l.ChangeKey(e, 7) != e || e.Value != 8 || l.Get(7) != 8 || l.Get(4) != "dup" {
+		t.Error(l)
+	}
+	if err := valid(l); nil != err || l.String() != "{0:0 1:2 2:4 3:6 4:dup 5:10 6:12 7:8 7:14 8:16 9:18}" {
+		t.Error(l, err)
+	}
+	if nil != l.ChangeKey(&Element{key: 3, links: make([]link, 1)}, 0) {
+		t.Error("moved foreign element")
+	}
+}
+
+func TestT_WithValueIndex(t *testing.T) {
+	t.Parallel()
+	mod4 := func(v interface{}) uint64 { return uint64(v.(int) % 4) }
+	for _, l := range []*T{skiplist(0, 9), skiplist(0, 9).WithValueIndex(mod4)} {
+		l.Set(3, 7).CompareAndSwap(4, 8, 9)
+		if !l.ContainsValue(7) || l.ContainsValue(6) || !l.ContainsValue(9) || l.ContainsValue(8) {
+			t.Error(l)
 		}
-		if e.Key().(int) != i {
-			t.Error("bad key")
+		if e := l.RemoveByValue(12); nil == e || e.Key() != 6 || nil != l.RemoveByValue(12) {
+			t.Error(e, l)
 		}
-		if e.Value.(int) != 2*i {
-			t.Error("bad value")
+		if c := l.Clone(); !c.ContainsValue(18) || c.Clear().ContainsValue(18) {
+			t.Error(c)
+		}
+		if err := valid(l); nil != err || l.String() != "{0:0 1:2 2:4 3:7 4:9 5:10 7:14 8:16 9:18}" {
+			t.Error(l, err)
 		}
-	}
-	if s.Len() != 0 {
-		t.Error("nonzero len")
 	}
 }
 
@@ -182,11 +2349,33 @@ func TestT_RemoveElement(t *testing.T) {
 	for i := 0; i <= 10; i += 2 {
 		l.RemoveElement(l.Element(i))
 	}
-	if fmt.Sprintf(l.String()) != "{1:2 3:6 5:10 7:14 9:18}" {
+	if l.String() != "{1:2 3:6 5:10 7:14 9:18}" {
 		t.Fail()
 	}
 }
 
+func TestT_RemoveElement_duplicates(t *testing.T) {
+	t.Parallel()
+	l := New()
+	for i := 0; i < 40; i++ {
+		l.Insert(i/10, i)
+	}
+	for l.Len() > 0 {
+		e := l.ElementN(l.Len() / 2)
+		if l.RemoveElement(e) != e {
+			t.Fatal("wrong element removed")
+		}
+		if l.RemoveElement(e) != nil {
+			t.Fatal("removed element removed again")
+		}
+		for i := 0; i < l.Len(); i++ {
+			if l.ElementN(i) == nil || l.ElementN(i) == e {
+				t.Fatal("corrupt list")
+			}
+		}
+	}
+}
+
 func TestT_RemoveN(t *testing.T) {
 	t.Parallel()
 	s := skiplist(0, 10)
@@ -215,6 +2404,78 @@ func TestT_RemoveN(t *testing.T) {
 	}
 }
 
+func TestT_RemoveNNext(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9)
+	for i := 0; i < 5; i++ {
+		removed, next := l.RemoveNNext(i)
+		if removed.Key() != 2*i || next != l.ElementN(i) || next.Key() != 2*i+1 {
+			t.Fatal(i, removed, next)
+		}
+	}
+	if removed, next := l.RemoveNNext(4); removed.Key() != 9 || next != nil {
+		t.Error(removed, next)
+	}
+	if removed, next := l.RemoveNNext(4); removed != nil || next != nil {
+		t.Error(removed, next)
+	}
+}
+
+func TestT_SpliceOut(t *testing.T) {
+	t.Parallel()
+	for n := 0; n < 40; n += 3 {
+		for lo := 0; lo <= n; lo += 2 {
+			for hi := lo; hi <= n; hi += 3 {
+				l := skiplist(0, n-1).Insert(n/2, -1)
+				want := append(append([]interface{}{}, l.Keys()[:lo]...), l.Keys()[hi:]...)
+				cut := l.Keys()[lo:hi]
+				seg := l.SpliceOut(lo, hi)
+				if err := valid(l); err != nil {
+					t.Fatal(n, lo, hi, err)
+				}
+				var got []interface{}
+				for e := seg.Front(); nil != e; e = e.Next() {
+					got = append(got, e.Key())
+				}
+				if seg.Len() != len(cut) || fmt.Sprint(got) != fmt.Sprint(cut) || fmt.Sprint(l.Keys()) != fmt.Sprint(want) {
+					t.Fatal(n, lo, hi, got, l)
+				}
+			}
+		}
+	}
+}
+
+func TestT_SpliceIn(t *testing.T) {
+	t.Parallel()
+	for n := 0; n < 40; n += 3 {
+		for lo := 0; lo <= n; lo += 2 {
+			for hi := lo; hi <= n; hi += 3 {
+				l := skiplist(0, n-1).Insert(n/2, -1)
+				want := l.String()
+				seg := l.SpliceOut(lo, hi)
+				l.SpliceIn(seg)
+				if err := valid(l); err != nil {
+					t.Fatal(n, lo, hi, err)
+				}
+				if l.String() != want || seg.Len() != 0 || seg.Front() != nil {
+					t.Fatal(n, lo, hi, l, want)
+				}
+			}
+		}
+	}
+	a, b := skiplist(0, 9), skiplist(20, 29)
+	a.SpliceIn(b.SpliceOut(0, b.Len()))
+	if err := valid(a); err != nil || a.Len() != 20 || b.Len() != 0 {
+		t.Error(err, a, b)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("misfit segment spliced in")
+		}
+	}()
+	a.SpliceIn(skiplist(5, 15).SpliceOut(0, 11))
+}
+
 func TestT_ElementPos(t *testing.T) {
 	t.Parallel()
 	l := skiplist(1, 10)
@@ -264,6 +2525,178 @@ func TestT_Pos(t *testing.T) {
 	}
 }
 
+func TestT_CountRange(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9).Insert(5, 5)
+	if l.CountRange(0, 10) != 11 || l.CountRange(5, 6) != 2 || l.CountRange(-5, 3) != 3 {
+		t.Fail()
+	}
+	if l.CountRange(4, 4) != 0 || l.CountRange(7, 2) != 0 || l.CountRange(20, 30) != 0 {
+		t.Fail()
+	}
+	if New().CountRange(1, 2) != 0 {
+		t.Fail()
+	}
+}
+
+func TestT_HasRange(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 4).Insert(8, 8)
+	if !l.HasRange(0, 1) || !l.HasRange(-5, 3) || !l.HasRange(5, 9) {
+		t.Fail()
+	}
+	if l.HasRange(5, 8) || l.HasRange(4, 4) || l.HasRange(3, 2) || l.HasRange(9, 20) {
+		t.Fail()
+	}
+	if New().HasRange(1, 2) {
+		t.Fail()
+	}
+}
+
+func TestT_CooperativeForEach(t *testing.T) {
+	t.Parallel()
+	for _, every := range []int{-1, 0, 1, 3, 100} {
+		sum := 0
+		skiplist(1, 10).CooperativeForEach(func(e *Element) { sum += e.Key().(int) }, every)
+		if sum != 55 {
+			t.Error(every, sum)
+		}
+	}
+}
+
+func TestT_Keys(t *testing.T) {
+	t.Parallel()
+	if fmt.Sprint(skiplist(1, 4).Insert(2, 0).Keys()) != "[1 2 2 3 4]" || len(New().Keys()) != 0 {
+		t.Fail()
+	}
+}
+
+func TestT_Values(t *testing.T) {
+	t.Parallel()
+	if fmt.Sprint(skiplist(1, 4).Insert(2, 0).Values()) != "[2 0 4 6 8]" || len(New().Values()) != 0 {
+		t.Fail()
+	}
+}
+
+func TestT_TrackBelow(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9).TrackBelow(5).TrackBelow(0)
+	l.Insert(3, 0).Set(7, 0).Remove(8)
+	l.RemoveN(0)
+	l.RemoveElement(l.Element(4))
+	below5, below0 := l.CountBelow(5), l.CountBelow(0)
+	l.tracked = nil
+	if below5 != 4 || below5 != l.CountBelow(5) || below0 != 0 || below0 != l.CountBelow(0) {
+		t.Error(below5, below0)
+	}
+}
+
+func TestT_CountBelow(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9).Insert(4, 0)
+	if l.CountBelow(-1) != 0 || l.CountBelow(4) != 4 || l.CountBelow(4.5) != 6 || l.CountBelow(10) != 11 {
+		t.Fail()
+	}
+	if New().CountBelow(0) != 0 {
+		t.Fail()
+	}
+}
+
+func TestT_ToMap(t *testing.T) {
+	t.Parallel()
+	m := skiplist(1, 3).Insert(2, "old").Insert(2, "young").ToMap()
+	if len(m) != 3 || m[1] != 2 || m[2] != "young" || m[3] != 6 {
+		t.Error(m)
+	}
+	m = New().Insert([]byte("a"), 1).ToMap()
+	if len(m) != 1 || m["a"] != 1 {
+		t.Error(m)
+	}
+	m = New().Insert(net.ParseIP("10.0.0.1"), 1).Insert(net.IPv4(10, 0, 0, 1).To4(), 2).Insert(net.ParseIP("::1"), 3).ToMap()
+	if len(m) != 2 || m[netip.MustParseAddr("10.0.0.1")] != 2 || m[netip.IPv6Loopback()] != 3 {
+		t.Error(m)
+	}
+	l := New().Insert(net.ParseIP("10.0.0.1"), 1).WithKeyIndex()
+	if l.Get(net.IPv4(10, 0, 0, 1).To4()) != 1 {
+		t.Error(l)
+	}
+	l = New().Insert(Tuple("a", []byte("b")), 1).Insert(Tuple("a"), 2).Insert(Tuple(), 3).WithKeyIndex()
+	m = l.ToMap()
+	if len(m) != 3 || m[Tuple("a", []byte("b")).MapKey()] != 1 || m[Tuple("a").MapKey()] != 2 || m[Tuple().MapKey()] != 3 {
+		t.Error(m)
+	}
+	if l.Get(Tuple("a", []byte("b"))) != 1 || l.Get(Tuple("a")) != 2 {
+		t.Error(l)
+	}
+}
+
+func TestT_All(t *testing.T) {
+	t.Parallel()
+	var got []interface{}
+	skiplist(1, 5).All()(func(k, v interface{}) bool {
+		got = append(got, k, v)
+		return k.(int) < 3
+	})
+	if fmt.Sprint(got) != "[1 2 2 4 3 6]" {
+		t.Error(got)
+	}
+}
+
+func TestT_AllKeys(t *testing.T) {
+	t.Parallel()
+	var got []interface{}
+	take(2, skiplist(1, 5).AllKeys())(func(k interface{}) bool {
+		got = append(got, k)
+		return true
+	})
+	if fmt.Sprint(got) != "[1 2]" {
+		t.Error(got)
+	}
+}
+
+func TestT_AllValues(t *testing.T) {
+	t.Parallel()
+	var got []interface{}
+	take(10, skiplist(1, 3).AllValues())(func(v interface{}) bool {
+		got = append(got, v)
+		return true
+	})
+	if fmt.Sprint(got) != "[2 4 6]" {
+		t.Error(got)
+	}
+}
+
+func TestT_Range(t *testing.T) {
+	t.Parallel()
+	var got []interface{}
+	skiplist(1, 9).Range(3, 6)(func(k, v interface{}) bool {
+		got = append(got, k)
+		return true
+	})
+	if fmt.Sprint(got) != "[3 4 5]" {
+		t.Error(got)
+	}
+	New().Range(1, 2)(func(k, v interface{}) bool {
+		t.Error("empty list yielded", k)
+		return true
+	})
+}
+
+func TestT_Clear(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 9).TrackBelow(5)
+	if l.Clear().Len() != 0 || l.String() != "{}" || l.CountBelow(5) != 0 || nil != l.Element(1) {
+		t.Fatal(l)
+	}
+	l.Insert(2, 2).Insert(1, 1)
+	if err := valid(l); err != nil || l.String() != "{1:1 2:2}" || l.CountBelow(5) != 2 {
+		t.Error(err, l)
+	}
+	if d := NewDescending().Insert(1, 1).Clear().Insert(1, 1).Insert(2, 2); d.String() != "{2:2 1:1}" {
+		t.Error(d)
+	}
+}
+
 func TestT_Len(t *testing.T) {
 	t.Parallel()
 	s := skiplist(0, 4)
@@ -287,6 +2720,91 @@ func TestT_ElementN(t *testing.T) {
 	}
 }
 
+func TestT_RenamePrefix(t *testing.T) {
+	t.Parallel()
+	l := New().Insert("a/1", 1).Insert("b/1", 2).Insert("b/2", 3).Insert("b/2", 4).Insert("c/1", 5)
+	if l.RenamePrefix([]byte("b/"), []byte("d/")) != 3 {
+		t.Error("bad count")
+	}
+	if l.String() != "{a/1:1 c/1:5 d/1:2 d/2:4 d/2:3}" {
+		t.Error(l)
+	}
+	d := NewDescending().Insert("a", 1).Insert("ab", 2).Insert("abc", 3).Insert("b", 4)
+	if d.RenamePrefix([]byte("ab"), []byte("c")) != 2 || d.String() != "{cc:3 c:2 b:4 a:1}" {
+		t.Error(d)
+	}
+	b := New().Insert([]byte("x1"), 1).Insert([]byte("y1"), 2)
+	if b.RenamePrefix([]byte("x"), []byte("z")) != 1 || b.String() != "{[121 49]:2 [122 49]:1}" {
+		t.Error(b)
+	}
+	if New().RenamePrefix([]byte("x"), []byte("y")) != 0 {
+		t.Error("empty")
+	}
+
+	// Renamed entries keep their Elements, and may fall among others.
+
+	l = New().WithScorePrefix("t/").Insert("t/a1", 1).Insert("t/a2", 2).Insert("t/b0", 3).Insert("t/b15", 4)
+	e := l.Element("t/a2")
+	if l.RenamePrefix([]byte("t/a"), []byte("t/b1")) != 2 || l.String() != "{t/b0:3 t/b11:1 t/b12:2 t/b15:4}" {
+		t.Error(l)
+	}
+	if l.Element("t/b12") != e || nil != valid(l) {
+		t.Error(e, valid(l))
+	}
+	e = l.Element("t/b0")
+	if l.RenamePrefix([]byte("t/b0"), []byte("t/c")) != 1 || l.Element("t/c") != e || nil != valid(l) {
+		t.Error(l)
+	}
+	defer func() {
+		if nil == recover() {
+			t.Error("RenamePrefix on a FoldCase list did not panic")
+		}
+	}()
+	New().FoldCase().Insert("a", 1).RenamePrefix([]byte("a"), []byte("b"))
+}
+
+func TestT_ElementNFrom(t *testing.T) {
+	t.Parallel()
+	l := skiplist(0, 99)
+	for from := 0; from < 100; from += 7 {
+		for delta := 0; delta < 110; delta += 3 {
+			e := l.ElementNFrom(l.ElementN(from), delta)
+			if from+delta < 100 && (nil == e || e.Key() != from+delta) || from+delta >= 100 && nil != e {
+				t.Fatal(from, delta, e)
+			}
+		}
+	}
+	if nil != l.ElementNFrom(l.Front(), -1) {
+		t.Error("negative delta")
+	}
+
+	// Removals leave stale levels in towers, which must not be followed.
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 100; i < 1100; i++ {
+		l.Insert(i, i)
+	}
+	for e := l.Front(); nil != e && l.Len() > 40; {
+		// Keep the tallest towers.
+		next := e.Next()
+		if len(e.links) < 5 {
+			l.RemoveElement(e)
+		}
+		e = next
+	}
+	for i := 0; i < 100; i++ {
+		l.Insert(2000+i, i)
+		l.RemoveN(rng.Intn(l.Len()))
+	}
+	for from := 0; from < l.Len(); from++ {
+		for delta := 0; from+delta <= l.Len(); delta++ {
+			if e := l.ElementNFrom(l.ElementN(from), delta); e != l.ElementN(from+delta) {
+				t.Fatal(from, delta, e)
+			}
+		}
+	}
+}
+
 func TestBuiltins(t *testing.T) {
 	t.Parallel()
 
@@ -493,8 +3011,8 @@ func ExampleT_GetAll() {
 
 func ExampleT_String() {
 	skip := New().Insert(1, 10).Insert(2, 20).Insert(3, 30)
-	fmt.Println(skip)
-	// Output: {1:10 2:20 3:30}
+	fmt.Println(skip, New())
+	// Output: {1:10 2:20 3:30} {}
 }
 
 // One may Remove() during iteration.
@@ -528,10 +3046,10 @@ func TestVisualization(t *testing.T) {
 	}
 	v := s.visualization()
 	expected := "" +
-		"L4 |---------------------------------------------------------------------->/\n" +
-		"L3 |---------------------->|---->|---------------------------------------->/\n" +
-		"L2 |---------------------->|->|->|---------------->|---------------------->/\n" +
-		"L1 |------->|------------->|->|->|->|---->|---->|->|---->|---------->|---->/\n" +
+		"L4 |------------------------------------------------------->|------------->/\n" +
+		"L3 |------------------------------->|------------------->|->|------------->/\n" +
+		"L2 |------------------------------->|------------------->|->|------------->/\n" +
+		"L1 |------->|->|------->|---------->|------->|------->|->|->|---->|------->/\n" +
 		"L0 |->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->|->/\n" +
 		"      0  0  0  0  0  0  0  0  0  0  0  0  0  0  0  0  1  1  1  1  1  1  1\n" +
 		"      0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f  0  1  2  3  4  5  6"
@@ -553,6 +3071,15 @@ func BenchmarkT_Insert_forward(b *testing.B) {
 	}
 }
 
+func BenchmarkT_Insert_sizeHint(b *testing.B) {
+	b.StopTimer()
+	s := New().WithSizeHint(b.N)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		s.Insert(i, i)
+	}
+}
+
 func BenchmarkT_Insert_reverse(b *testing.B) {
 	b.StopTimer()
 	s := New()
@@ -640,6 +3167,17 @@ func BenchmarkT_ElementN_shuffle(b *testing.B) {
 	}
 }
 
+func BenchmarkT_ElementNFrom_stride(b *testing.B) {
+	b.StopTimer()
+	s := skiplist(0, 1<<16)
+	b.StartTimer()
+	for i, e := 0, s.Front(); i < b.N; i++ {
+		if e = s.ElementNFrom(e, 100); nil == e {
+			e = s.Front()
+		}
+	}
+}
+
 func BenchmarkT_Remove_forward(b *testing.B) {
 	b.StopTimer()
 	s := New()
@@ -721,6 +3259,57 @@ func skiplist(min, max int) *T {
 	return s
 }
 
+// Function valid returns an error describing the first broken invariant of
+// list l, or nil: each level must link, in order, a subset of the elements
+// at the level below, with widths matching positions.
+//
+func valid(l *T) error {
+	levels := 0
+	for n := l.cnt; n > 0; n >>= 1 {
+		levels++
+	}
+	if len(l.links) != levels {
+		return fmt.Errorf("%d levels for %d elements", len(l.links), l.cnt)
+	}
+	pos := map[*Element]int{}
+	i := 0
+	for e := l.Front(); nil != e; e = e.Next() {
+		if i > 0 && l.before(e, l.ElementN(i-1).key, l.ElementN(i-1).score) {
+			return fmt.Errorf("element %d out of order", i)
+		}
+		pos[e] = i
+		i++
+	}
+	if i != l.cnt {
+		return fmt.Errorf("%d elements counted as %d", i, l.cnt)
+	}
+	for level := range l.links {
+		p, link := -1, l.links[level]
+		for ; nil != link.to; link = link.to.links[level] {
+			to, ok := pos[link.to]
+			if !ok || to <= p || to-p != link.width {
+				return fmt.Errorf("L%d link from %d to %d has width %d", level, p, to, link.width)
+			}
+			p = to
+		}
+		if l.cnt-p != link.width {
+			return fmt.Errorf("L%d final link from %d has width %d", level, p, link.width)
+		}
+	}
+	return nil
+}
+
+// Take is an iterator combinator yielding at most n values of seq.
+//
+func take(n int, seq func(func(interface{}) bool)) func(func(interface{}) bool) {
+	return func(yield func(interface{}) bool) {
+		seq(func(v interface{}) bool {
+			n--
+			return n >= 0 && yield(v) && n > 0
+		})
+	}
+}
+
 // Create an arrow string like "|-->" that is cnt runes long.
 //
 func arrow(cnt int) (s string) {
@@ -0,0 +1,149 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package timerq provides a queue of callbacks keyed by deadline, fired by
+// a runner goroutine when they come due.  The callbacks are held in a
+// skiplist, so scheduling, cancelling and firing each take O(log(N))
+// time, however many timers are pending.
+//
+package timerq
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"sync"
+	"time"
+)
+
+// A Queue holds scheduled callbacks and runs them when due, on its own
+// goroutine.  Its methods are safe for concurrent use, including by
+// callbacks.
+//
+type Queue struct {
+	l       *skiplist.T
+	mu      sync.Mutex
+	seq     uint64
+	stop    chan struct{}
+	stopped bool
+	wake    chan struct{}
+}
+
+// A Timer is the handle of a scheduled callback, for Cancel.
+//
+type Timer struct {
+	e  *skiplist.Element
+	fn func()
+}
+
+// A key orders timers by deadline, then by the order scheduled,
+// implementing skiplist.FastKey.
+//
+type key struct {
+	at  int64
+	seq uint64
+}
+
+func (a key) Less(b interface{}) bool {
+	k := b.(key)
+	return a.at < k.at || a.at == k.at && a.seq < k.seq
+}
+
+func (a key) Score() float64 { return float64(a.at) }
+
+// New returns a new, empty Queue, and starts its runner goroutine.
+//
+func New() *Queue {
+	q := &Queue{l: skiplist.New(), stop: make(chan struct{}), wake: make(chan struct{}, 1)}
+	go q.run()
+	return q
+}
+
+// Len returns the number of callbacks pending.
+//
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.l.Len()
+}
+
+// Schedule arranges for fn to be called at time at, or as soon as
+// possible if at has passed, in O(log(N)) time, returning a Timer for
+// Cancel.  Callbacks due at the same time are called in the order
+// scheduled.  Callbacks are called one at a time on the runner goroutine,
+// so a slow callback should start its own goroutine.
+//
+func (q *Queue) Schedule(at time.Time, fn func()) *Timer {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := &Timer{fn: fn}
+	q.seq++
+	t.e = q.l.InsertElem(key{at.UnixNano(), q.seq}, t)
+	if q.l.Front() == t.e {
+		// The runner may be sleeping until a later deadline.
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return t
+}
+
+// Cancel prevents the callback of t from being called, in O(log(N))
+// time, and reports whether it did: false if the callback has already
+// been called or started, or t was already cancelled.
+//
+func (q *Queue) Cancel(t *Timer) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if nil == t.e {
+		return false
+	}
+	q.l.RemoveElement(t.e)
+	t.e = nil
+	return true
+}
+
+// Stop stops the runner goroutine.  Pending callbacks are not called.
+//
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.stop)
+	}
+}
+
+// Function run calls callbacks as they come due, sleeping until the
+// earliest deadline or until woken by Schedule or Stop.
+//
+func (q *Queue) run() {
+	timer := time.NewTimer(time.Hour)
+	for {
+		q.mu.Lock()
+		now := time.Now().UnixNano()
+		var due []func()
+		for e := q.l.Front(); nil != e && e.Key().(key).at <= now; e = q.l.Front() {
+			t := q.l.RemoveN(0).Value.(*Timer)
+			t.e = nil
+			due = append(due, t.fn)
+		}
+		wait := time.Hour
+		if e := q.l.Front(); nil != e {
+			wait = time.Duration(e.Key().(key).at - now)
+		}
+		q.mu.Unlock()
+
+		// Call the callbacks without the lock, so they may use the queue.
+
+		for _, fn := range due {
+			fn()
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-q.wake:
+		case <-q.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package timerq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+	q := New()
+	defer q.Stop()
+	fired := make(chan int, 10)
+	start := time.Now()
+	for _, i := range []int{3, 1, 4, 2} {
+		q.Schedule(start.Add(time.Duration(i)*20*time.Millisecond), func() { fired <- i })
+	}
+	cancelled := q.Schedule(start.Add(30*time.Millisecond), func() { fired <- 0 })
+	q.Schedule(start.Add(-time.Second), func() { fired <- -1 })
+	if !q.Cancel(cancelled) || q.Cancel(cancelled) {
+		t.Error("Cancel")
+	}
+	for _, want := range []int{-1, 1, 2, 3, 4} {
+		if got := <-fired; got != want {
+			t.Error(got, want)
+		}
+	}
+	if time.Since(start) < 80*time.Millisecond {
+		t.Error("fired early")
+	}
+	if q.Len() != 0 {
+		t.Error(q.Len())
+	}
+}
+
+func TestScheduleFromCallback(t *testing.T) {
+	t.Parallel()
+	q := New()
+	done := make(chan bool)
+	first := make(chan *Timer, 1)
+	first <- q.Schedule(time.Now(), func() {
+		if q.Cancel(<-first) {
+			t.Error("cancelled a fired timer")
+		}
+		q.Schedule(time.Now().Add(time.Millisecond), func() { done <- true })
+	})
+	<-done
+	q.Stop()
+	q.Stop()
+	q.Schedule(time.Now(), func() { t.Error("fired after Stop") })
+	time.Sleep(10 * time.Millisecond)
+	if q.Len() != 1 {
+		t.Error(q.Len())
+	}
+}
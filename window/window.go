@@ -0,0 +1,120 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package window provides order statistics, such as the minimum, maximum
+// and median, over a sliding window of timestamped samples.  Samples are
+// evicted once they are older than the window's span, or once the window
+// holds too many, oldest first.
+//
+package window
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"math"
+	"time"
+)
+
+// A Window holds the recent samples of a series.  Like a skiplist, it is
+// not safe for concurrent use.
+//
+type Window struct {
+	byTime *skiplist.T
+	latest int64
+	max    int
+	seq    uint64
+	span   time.Duration
+	values *skiplist.T
+}
+
+// A key orders the samples of a Window by time, and samples with equal
+// times by arrival, implementing skiplist.FastKey.
+//
+type key struct {
+	at  int64
+	seq uint64
+}
+
+func (a key) Less(b interface{}) bool {
+	k := b.(key)
+	return a.at < k.at || a.at == k.at && a.seq < k.seq
+}
+
+func (a key) Score() float64 { return float64(a.at) }
+
+// New returns a new, empty Window holding samples no older than span
+// before the latest time seen, and at most max samples.  A span or max of
+// 0 imposes no limit of that kind.
+//
+func New(span time.Duration, max int) *Window {
+	return &Window{skiplist.New(), math.MinInt64, max, 0, span, skiplist.New()}
+}
+
+// Len returns the number of samples in the window in O(1) time.
+//
+func (w *Window) Len() int { return w.values.Len() }
+
+// Add adds sample v taken at time at, then evicts samples as needed, in
+// O(log(N)) time per sample added or evicted.  Samples may be added out
+// of time order; one already older than the span is evicted at once, and
+// of samples taken at the same time, the first added is evicted first.
+// Add panics if v is NaN.
+//
+func (w *Window) Add(at time.Time, v float64) {
+	if math.IsNaN(v) {
+		panic("window: NaN sample")
+	}
+	w.seq++
+	w.byTime.Insert(key{at.UnixNano(), w.seq}, w.values.InsertElem(v, nil))
+	if 0 < w.max && w.max < w.byTime.Len() {
+		w.evictOldest()
+	}
+	w.Advance(at)
+}
+
+// Advance evicts the samples older than span before now, or before the
+// latest time already seen, if later, in O(log(N)) time per sample
+// evicted.
+//
+func (w *Window) Advance(now time.Time) {
+	w.latest = max(w.latest, now.UnixNano())
+	if 0 == w.span {
+		return
+	}
+	for e := w.byTime.Front(); nil != e && e.Key().(key).at < w.latest-int64(w.span); e = w.byTime.Front() {
+		w.evictOldest()
+	}
+}
+
+// Function evictOldest removes the oldest sample from the window.
+//
+func (w *Window) evictOldest() {
+	w.values.RemoveElement(w.byTime.RemoveN(0).Value.(*skiplist.Element))
+}
+
+// Min returns the least sample in the window in O(log(N)) time, or NaN if
+// the window is empty.
+//
+func (w *Window) Min() float64 { return w.Quantile(0) }
+
+// Max returns the greatest sample in the window in O(log(N)) time, or NaN
+// if the window is empty.
+//
+func (w *Window) Max() float64 { return w.Quantile(1) }
+
+// Median returns the median sample in the window, or the lower of the
+// middle two, in O(log(N)) time.  It returns NaN if the window is empty.
+//
+func (w *Window) Median() float64 { return w.Quantile(0.5) }
+
+// Quantile returns the sample at quantile q, from 0 to 1, of the window,
+// by the nearest-rank method, in O(log(N)) time.  It returns NaN if the
+// window is empty, and panics unless 0 <= q <= 1.
+//
+func (w *Window) Quantile(q float64) float64 {
+	if !(0 <= q && q <= 1) {
+		panic("window: quantile out of range [0,1]")
+	}
+	if e := w.values.Quantile(q); nil != e {
+		return e.Key().(float64)
+	}
+	return math.NaN()
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package window
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSpan(t *testing.T) {
+	t.Parallel()
+	w := New(10*time.Second, 0)
+	if !math.IsNaN(w.Median()) {
+		t.Error("empty window")
+	}
+	at := time.Unix(1000, 0)
+	for i := 0; i < 20; i++ {
+		w.Add(at.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	if w.Len() != 11 || w.Min() != 9 || w.Max() != 19 || w.Median() != 14 {
+		t.Error(w.Len(), w.Min(), w.Max(), w.Median())
+	}
+
+	// A late sample inside the span is kept, and one outside it is not.
+
+	w.Add(at.Add(12*time.Second), 100)
+	w.Add(at.Add(5*time.Second), -100)
+	if w.Len() != 12 || w.Min() != 9 || w.Max() != 100 {
+		t.Error(w.Len(), w.Min(), w.Max())
+	}
+	w.Advance(at.Add(28 * time.Second))
+	if w.Len() != 2 || w.Quantile(0.5) != 18 {
+		t.Error(w.Len(), w.Quantile(0.5))
+	}
+	w.Advance(at)
+	if w.Len() != 2 {
+		t.Error("Advance went back in time")
+	}
+}
+
+func TestMax(t *testing.T) {
+	t.Parallel()
+	w := New(0, 3)
+	at := time.Unix(0, 0)
+	for _, v := range []float64{5, 1, 9, 7, 3} {
+		w.Add(at, v)
+		at = at.Add(time.Hour)
+	}
+	if w.Len() != 3 || w.Min() != 3 || w.Max() != 9 || w.Median() != 7 {
+		t.Error(w.Len(), w.Min(), w.Max(), w.Median())
+	}
+
+	// Of samples taken at the same time, the first added is evicted first.
+
+	w = New(0, 3)
+	for v := 1; v <= 5; v++ {
+		w.Add(at, float64(v))
+	}
+	if w.Len() != 3 || w.Min() != 3 || w.Max() != 5 {
+		t.Error(w.Len(), w.Min(), w.Max())
+	}
+	defer func() {
+		if r := recover(); r != "window: NaN sample" {
+			t.Error(r)
+		}
+	}()
+	w.Add(at, math.NaN())
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package zorder provides Z-order (Morton) keys, which index 2D points in
+// a skiplist by interleaving the bits of their coordinates, so points
+// near each other tend to have keys near each other.  Scan finds the
+// points in a rectangle by scanning the few key ranges that cover it.
+//
+package zorder
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"sort"
+)
+
+// A Key is the Z-order key of a point, implementing skiplist.FastKey.  Bit
+// i of x is bit 2i of the key, and bit i of y is bit 2i+1.
+//
+type Key uint64
+
+// Encode returns the key for the point (x,y).
+//
+func Encode(x, y uint32) Key {
+	return Key(spread(x) | spread(y)<<1)
+}
+
+// XY returns the coordinates of the point with key k.
+//
+func (k Key) XY() (x, y uint32) {
+	return compact(uint64(k)), compact(uint64(k) >> 1)
+}
+
+func (k Key) Less(b interface{}) bool { return k < b.(Key) }
+
+func (k Key) Score() float64 { return float64(k) }
+
+// Function spread returns the bits of v at the even bit positions.
+//
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// Function compact returns the even bits of v, inverting spread.
+//
+func compact(v uint64) uint32 {
+	x := v & 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return uint32(x)
+}
+
+// A Rect is the rectangle of points with MinX <= x <= MaxX and
+// MinY <= y <= MaxY.
+//
+type Rect struct {
+	MinX, MinY, MaxX, MaxY uint32
+}
+
+// Contains reports whether the point with key k is in r.
+//
+func (r Rect) Contains(k Key) bool {
+	x, y := k.XY()
+	return r.MinX <= x && x <= r.MaxX && r.MinY <= y && y <= r.MaxY
+}
+
+// A Range is the interval of keys [Lo,Hi].
+//
+type Range struct {
+	Lo, Hi Key
+}
+
+// Ranges returns at most max ranges of keys, in order, covering the keys
+// of the points in r.  The rectangle is split like a quadtree into cells,
+// each a range of keys, splitting cells that straddle its edge until
+// splitting further would need more than max ranges.  Straddling cells
+// that remain unsplit cover points outside r, so keys in the ranges must
+// still be checked with Contains.  Adjacent ranges are merged.
+//
+func Ranges(r Rect, max int) []Range {
+	if r.MinX > r.MaxX || r.MinY > r.MaxY {
+		return nil
+	}
+	var out []Range
+	straddling := []Range{{0, ^Key(0)}}
+	for size := uint64(1) << 32; 0 < len(straddling); size >>= 1 {
+		if len(out)+4*len(straddling) > max {
+			out = append(out, straddling...)
+			break
+		}
+		var next []Range
+		for _, cell := range straddling {
+			span := Key(size/2) * Key(size/2)
+			for i := Key(0); i < 4; i++ {
+				lo := cell.Lo + i*span
+				child := Range{lo, lo + span - 1}
+				x0, y0 := lo.XY()
+				x1, y1 := child.Hi.XY()
+				switch {
+				case x1 < r.MinX || r.MaxX < x0 || y1 < r.MinY || r.MaxY < y0:
+				case r.MinX <= x0 && x1 <= r.MaxX && r.MinY <= y0 && y1 <= r.MaxY:
+					out = append(out, child)
+				default:
+					next = append(next, child)
+				}
+			}
+		}
+		straddling = next
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Lo < out[j].Lo })
+	merged := out[:0]
+	for _, rg := range out {
+		if n := len(merged); 0 < n && merged[n-1].Hi+1 == rg.Lo {
+			merged[n-1].Hi = rg.Hi
+		} else {
+			merged = append(merged, rg)
+		}
+	}
+	return merged
+}
+
+// Scan calls fn for each element of l, whose keys must be Keys, with a
+// key in r, in key order, until fn returns false.  It scans at most max
+// ranges of keys, as from Ranges, each in O(log(N)) time plus time for
+// the elements in the range.
+//
+func Scan(l *skiplist.T, r Rect, max int, fn func(e *skiplist.Element) bool) {
+	for _, rg := range Ranges(r, max) {
+		for e := l.ElementN(l.PosCeiling(rg.Lo)); nil != e && e.Key().(Key) <= rg.Hi; e = e.Next() {
+			if r.Contains(e.Key().(Key)) && !fn(e) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package zorder
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"math/rand"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+	if k := Encode(0b101, 0b011); k != 0b011011 {
+		t.Errorf("%b", k)
+	}
+	for i := 0; i < 1000; i++ {
+		x, y := rand.Uint32(), rand.Uint32()
+		if gx, gy := Encode(x, y).XY(); gx != x || gy != y {
+			t.Error(x, y, gx, gy)
+		}
+	}
+	if Encode(^uint32(0), ^uint32(0)) != ^Key(0) {
+		t.Error("max key")
+	}
+}
+
+func TestRanges(t *testing.T) {
+	t.Parallel()
+	r := Rect{2, 2, 5, 3}
+	rgs := Ranges(r, 100)
+	n := Key(0)
+	for _, rg := range rgs {
+		for k := rg.Lo; k <= rg.Hi; k++ {
+			if !r.Contains(k) {
+				t.Error("exact ranges cover", k)
+			}
+			n++
+		}
+	}
+	if n != 8 {
+		t.Error(rgs)
+	}
+	if rgs := Ranges(r, 1); len(rgs) != 1 || rgs[0] != (Range{0, ^Key(0)}) {
+		t.Error(rgs)
+	}
+	if nil != Ranges(Rect{1, 0, 0, 0}, 10) {
+		t.Error("empty rect")
+	}
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+	l := skiplist.New()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		l.Insert(Encode(uint32(rng.Intn(1000)), uint32(rng.Intn(1000))), i)
+	}
+	for i := 0; i < 50; i++ {
+		x, y := uint32(rng.Intn(1000)), uint32(rng.Intn(1000))
+		r := Rect{x, y, x + uint32(rng.Intn(200)), y + uint32(rng.Intn(200))}
+		want := 0
+		for e := l.Front(); nil != e; e = e.Next() {
+			if r.Contains(e.Key().(Key)) {
+				want++
+			}
+		}
+		for _, max := range []int{1, 8, 64} {
+			got, last := 0, Key(0)
+			Scan(l, r, max, func(e *skiplist.Element) bool {
+				if k := e.Key().(Key); k < last || !r.Contains(k) {
+					t.Error(r, k)
+				} else {
+					last = k
+				}
+				got++
+				return true
+			})
+			if got != want {
+				t.Error(r, max, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+// Package zset provides sorted sets of members with scores, with the
+// semantics of Redis's ZSET, as for leaderboards.  Members are ordered by
+// score, and members with equal scores by member.  A hash from members to
+// skiplist elements makes finding a member's score O(1), and its rank
+// O(log(N)).
+//
+package zset
+
+import (
+	"github.com/glenn-brown/skiplist"
+	"math"
+)
+
+// A Set is a sorted set of members with scores.
+//
+type Set struct {
+	l       *skiplist.T
+	members map[string]*skiplist.Element
+}
+
+// An Entry is a member of a Set and its score.
+//
+type Entry struct {
+	Member string
+	Score  float64
+}
+
+// A key orders the entries of a Set, implementing skiplist.FastKey.
+//
+type key struct {
+	member string
+	score  float64
+}
+
+func (a key) Less(b interface{}) bool {
+	k := b.(key)
+	return a.score < k.score || a.score == k.score && a.member < k.member
+}
+
+func (a key) Score() float64 { return a.score }
+
+// New returns a new, empty Set.
+//
+func New() *Set {
+	return &Set{skiplist.New(), map[string]*skiplist.Element{}}
+}
+
+// Len returns the number of members of the set in O(1) time.
+//
+func (z *Set) Len() int { return len(z.members) }
+
+// Add sets the score of member, adding it to the set if it is not already
+// a member, in O(log(N)) time, and reports whether it was added.  It
+// panics if score is NaN.
+//
+func (z *Set) Add(member string, score float64) (added bool) {
+	if math.IsNaN(score) {
+		panic("zset: NaN score")
+	}
+	if e, ok := z.members[member]; ok {
+		if e.Key().(key).score != score {
+			z.l.ChangeKey(e, key{member, score})
+		}
+		return false
+	}
+	z.members[member] = z.l.InsertElem(key{member, score}, nil)
+	return true
+}
+
+// Remove removes member from the set in O(log(N)) time, and reports
+// whether it was a member.
+//
+func (z *Set) Remove(member string) bool {
+	e, ok := z.members[member]
+	if !ok {
+		return false
+	}
+	z.l.RemoveElement(e)
+	delete(z.members, member)
+	return true
+}
+
+// Score returns the score of member in O(1) time.  If it is not a member,
+// ok is false.
+//
+func (z *Set) Score(member string) (score float64, ok bool) {
+	if e, ok := z.members[member]; ok {
+		return e.Key().(key).score, true
+	}
+	return 0, false
+}
+
+// Rank returns the position of member in the set, from 0 for the lowest
+// score, in O(log(N)) time.  If it is not a member, ok is false.
+//
+func (z *Set) Rank(member string) (rank int, ok bool) {
+	if e, ok := z.members[member]; ok {
+		return z.l.Pos(e.Key()), true
+	}
+	return -1, false
+}
+
+// IncrBy adds delta to the score of member, adding it with score delta if
+// it is not a member, in O(log(N)) time, and returns the new score.  It
+// panics if the new score is NaN.
+//
+func (z *Set) IncrBy(member string, delta float64) float64 {
+	score, _ := z.Score(member)
+	z.Add(member, score+delta)
+	return score + delta
+}
+
+// RangeByRank returns the entries from rank start to rank stop, inclusive,
+// in order, in O(log(N)+V) time, where V is the number returned.  Negative
+// ranks count back from the end of the set, so -1 is the highest score.
+//
+func (z *Set) RangeByRank(start, stop int) []Entry {
+	n := z.Len()
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return nil
+	}
+	entries := make([]Entry, 0, stop-start+1)
+	for e := z.l.ElementN(start); len(entries) < cap(entries); e = e.Next() {
+		k := e.Key().(key)
+		entries = append(entries, Entry{k.member, k.score})
+	}
+	return entries
+}
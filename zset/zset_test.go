@@ -0,0 +1,58 @@
+// Copyright (c) 2012, Glenn Brown.  All rights reserved.  See LICENSE.
+
+package zset
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+	z := New()
+	if !z.Add("carol", 30) || !z.Add("alice", 10) || !z.Add("bob", 20) || !z.Add("dave", 20) {
+		t.Error("add")
+	}
+	if z.Add("alice", 25) || z.Len() != 4 {
+		t.Error("re-add", z.Len())
+	}
+	if s, ok := z.Score("alice"); !ok || s != 25 {
+		t.Error(s, ok)
+	}
+	for member, want := range map[string]int{"bob": 0, "dave": 1, "alice": 2, "carol": 3} {
+		if r, ok := z.Rank(member); !ok || r != want {
+			t.Error(member, r, ok)
+		}
+	}
+	if r, ok := z.Rank("eve"); ok || r != -1 {
+		t.Error(r, ok)
+	}
+	if s := z.IncrBy("bob", 15); s != 35 {
+		t.Error(s)
+	}
+	if s := z.IncrBy("eve", -5); s != -5 {
+		t.Error(s)
+	}
+	if s := fmt.Sprint(z.RangeByRank(0, -1)); s != "[{eve -5} {dave 20} {alice 25} {carol 30} {bob 35}]" {
+		t.Error(s)
+	}
+	if s := fmt.Sprint(z.RangeByRank(-2, 10)); s != "[{carol 30} {bob 35}]" {
+		t.Error(s)
+	}
+	if nil != z.RangeByRank(3, 2) || nil != z.RangeByRank(5, 9) {
+		t.Error("empty ranges")
+	}
+	if !z.Remove("dave") || z.Remove("dave") || z.Len() != 4 {
+		t.Error("remove")
+	}
+	if _, ok := z.Score("dave"); ok {
+		t.Error("removed member scored")
+	}
+	defer func() {
+		if r := recover(); r != "zset: NaN score" {
+			t.Error(r)
+		}
+	}()
+	z.Add("nan", math.NaN())
+}